@@ -0,0 +1,161 @@
+package main
+
+import (
+    "time"
+)
+
+// Options controlling which (optional) aggregates are maintained in each bucket.
+//
+type BucketOptions struct {
+    TreeDepth    int
+    TopKCapacity int
+    TopKGrouping *Grouping
+    TopBandwidth int
+    Referers     int
+    Sessions     bool
+    Transitions  bool
+    QueryParams  bool
+    QueryValues  []string
+}
+
+// A Bucket holds everything aggregated over a single resolution tick.  Buckets are merged
+// together to summarize longer spans of time, so every aggregate held here must be mergeable.
+//
+type Bucket struct {
+    Duration    time.Duration
+    Totals      *LogStatistic
+    Sections    StatisticSet
+    Tree        *SectionTree
+    TopK        *SpaceSaving
+    ClientBytes *SpaceSaving
+    Referers    *RefererReport
+    Sessions    *SessionStats
+    Transitions *TransitionGraph
+    Query       *QueryReport
+
+    topKGrouping *Grouping
+}
+
+func NewBucket(options BucketOptions) *Bucket {
+    capacity := options.TopKCapacity
+
+    if capacity <= 0 {
+        capacity = DEFAULT_TOPK_CAPACITY
+    }
+
+    bucket := &Bucket{
+        Totals:   NewLogStatistic(`*`),
+        Sections: make(StatisticSet),
+    }
+
+    if options.TreeDepth > 0 {
+        bucket.Tree = NewSectionTree(options.TreeDepth)
+    }
+
+    if options.TopKGrouping != nil {
+        bucket.TopK = NewSpaceSaving(capacity)
+        bucket.topKGrouping = options.TopKGrouping
+    }
+
+    if options.TopBandwidth > 0 {
+        bucket.ClientBytes = NewSpaceSaving(capacity)
+    }
+
+    if options.Referers > 0 {
+        bucket.Referers = NewRefererReport(capacity)
+    }
+
+    if options.Sessions {
+        bucket.Sessions = NewSessionStats(capacity)
+    }
+
+    if options.Transitions {
+        bucket.Transitions = NewTransitionGraph(capacity)
+    }
+
+    if options.QueryParams {
+        bucket.Query = NewQueryReport(options.QueryValues, capacity)
+    }
+
+    return bucket
+}
+
+// Record a log line in all of this bucket's section-independent aggregates.
+//
+func (self *Bucket) Observe(logLine *NcsaLog) {
+    self.Totals.Add(logLine)
+
+    if self.Tree != nil {
+        self.Tree.Add(logLine)
+    }
+
+    if self.TopK != nil {
+        if key, ok := self.topKGrouping.Key(logLine); ok {
+            self.TopK.Add(key)
+        }
+    }
+
+    if self.ClientBytes != nil {
+        self.ClientBytes.AddCount(logLine.ClientAddress(), logLine.Size, 0)
+    }
+
+    if self.Referers != nil {
+        self.Referers.Add(logLine)
+    }
+
+    if self.Query != nil {
+        self.Query.Add(logLine)
+    }
+}
+
+// Accumulate all aggregates from another bucket into this one.
+//
+func (self *Bucket) Merge(other *Bucket) {
+    if other == nil {
+        return
+    }
+
+    self.Duration += other.Duration
+    self.Totals.Merge(other.Totals)
+    self.Sections.Merge(other.Sections)
+
+    if self.Tree != nil && other.Tree != nil {
+        self.Tree.Merge(other.Tree)
+    }
+
+    if self.TopK != nil {
+        self.TopK.Merge(other.TopK)
+    }
+
+    if self.ClientBytes != nil {
+        self.ClientBytes.Merge(other.ClientBytes)
+    }
+
+    if self.Referers != nil {
+        self.Referers.Merge(other.Referers)
+    }
+
+    if self.Sessions != nil {
+        self.Sessions.Merge(other.Sessions)
+    }
+
+    if self.Transitions != nil {
+        self.Transitions.Merge(other.Transitions)
+    }
+
+    if self.Query != nil {
+        self.Query.Merge(other.Query)
+    }
+}
+
+// Return the amount of time this bucket covers, for the purpose of computing rates.  When
+// summarizing historical logs, the span of log timestamps is used if it is longer than the
+// time it took to read them.
+//
+func (self *Bucket) Elapsed() time.Duration {
+    if span := self.Totals.TimeSpan(); span > self.Duration {
+        return span
+    }
+
+    return self.Duration
+}
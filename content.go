@@ -0,0 +1,190 @@
+package main
+
+import (
+    "fmt"
+    "path"
+    "strings"
+)
+
+const CONTENT_HTML  = `html`
+const CONTENT_API   = `api`
+const CONTENT_OTHER = `other`
+
+const CACHE_HIT         = `hit`
+const CACHE_MISS        = `miss`
+const CACHE_EXPIRED     = `expired`
+const CACHE_STALE       = `stale`
+const CACHE_REVALIDATED = `revalidated`
+const CACHE_BYPASS      = `bypass`
+const CACHE_OTHER       = `other`
+
+// A rule assigning a content class to requests, either by the extension of the requested
+// path (e.g.: ".png") or by a path prefix (e.g.: "/api/").
+//
+type ContentRule struct {
+    Class  string
+    Match  string
+}
+
+func (self ContentRule) Matches(requestPath string, extension string) bool {
+    if strings.HasPrefix(self.Match, `/`) {
+        return strings.HasPrefix(requestPath, self.Match)
+    }
+
+    return extension == self.Match
+}
+
+// The rules used to classify requests, checked in order.  Paths with no extension (and those
+// not matching any rule) are classified as HTML pages and "other" respectively.
+//
+var ContentRules = []ContentRule{
+    { CONTENT_API, `/api/` },
+}
+
+// The default content class of each file extension.
+//
+var CONTENT_EXTENSIONS = map[string][]string{
+    `image`:    { `.png`, `.jpg`, `.jpeg`, `.gif`, `.svg`, `.ico`, `.webp`, `.avif`, `.bmp`, `.tif`, `.tiff` },
+    `script`:   { `.js`, `.mjs`, `.map`, `.wasm` },
+    `style`:    { `.css` },
+    `font`:     { `.woff`, `.woff2`, `.ttf`, `.otf`, `.eot` },
+    `media`:    { `.mp4`, `.webm`, `.m4v`, `.mov`, `.mp3`, `.m4a`, `.ogg`, `.wav`, `.m3u8`, `.ts`, `.mpd` },
+    `document`: { `.pdf`, `.doc`, `.docx`, `.xls`, `.xlsx`, `.ppt`, `.pptx`, `.txt`, `.csv` },
+    `data`:     { `.json`, `.xml`, `.rss`, `.atom` },
+    `archive`:  { `.zip`, `.gz`, `.tgz`, `.tar`, `.bz2`, `.xz`, `.7z`, `.dmg`, `.exe`, `.msi`, `.deb`, `.rpm` },
+    `html`:     { `.html`, `.htm`, `.xhtml`, `.php`, `.asp`, `.aspx`, `.jsp`, `.cgi`, `.shtml` },
+}
+
+var contentExtensions = make(map[string]string)
+
+func init() {
+    for class, extensions := range CONTENT_EXTENSIONS {
+        for _, extension := range extensions {
+            contentExtensions[extension] = class
+        }
+    }
+}
+
+// Add a rule from a "CLASS=MATCH[,MATCH...]" specification, where each MATCH is either a file
+// extension (e.g.: "image=.heic,.jxl") or a path prefix (e.g.: "api=/graphql,/rpc/").  Added
+// rules take precedence over the defaults.
+//
+func AddContentRule(spec string) error {
+    parts := strings.SplitN(spec, `=`, 2)
+
+    if len(parts) != 2 || strings.TrimSpace(parts[0]) == `` || strings.TrimSpace(parts[1]) == `` {
+        return fmt.Errorf("Invalid content class '%s': expected CLASS=EXTENSION[,...] or CLASS=/PREFIX[,...]", spec)
+    }
+
+    rules := make([]ContentRule, 0)
+
+    for _, match := range strings.Split(parts[1], `,`) {
+        match = strings.TrimSpace(match)
+
+        switch {
+        case match == ``:
+            continue
+        case strings.HasPrefix(match, `/`):
+        case strings.HasPrefix(match, `.`):
+            match = strings.ToLower(match)
+        default:
+            match = `.` + strings.ToLower(match)
+        }
+
+        rules = append(rules, ContentRule{
+            Class: strings.TrimSpace(parts[0]),
+            Match: match,
+        })
+    }
+
+    ContentRules = append(rules, ContentRules...)
+    return nil
+}
+
+// Return the content class of the given request path (e.g.: "image", "script", "html").
+//
+func ContentClass(requestPath string) string {
+    requestPath = RequestPath(requestPath)
+    extension := strings.ToLower(path.Ext(requestPath))
+
+    for _, rule := range ContentRules {
+        if rule.Matches(requestPath, extension) {
+            return rule.Class
+        }
+    }
+
+    if extension == `` {
+        return CONTENT_HTML
+    }
+
+    if class, ok := contentExtensions[extension]; ok {
+        return class
+    }
+
+    return CONTENT_OTHER
+}
+
+// Fields that may hold the cache status of a response (as logged by nginx, Varnish or a CDN),
+// in order of preference.
+//
+var CACHE_STATUS_FIELDS = []string{
+    `upstream_cache_status`,
+    `cache_status`,
+    `cache`,
+    `x_cache`,
+    `x-cache`,
+    `cf_cache_status`,
+    `cf-cache-status`,
+    `srcache_fetch_status`,
+}
+
+// Tokens within a cache status value identifying its outcome, checked in order.
+//
+var CACHE_STATUS_TOKENS = []struct{
+    Token  string
+    Status string
+}{
+    { `REVALIDATED`, CACHE_REVALIDATED },
+    { `EXPIRED`,     CACHE_EXPIRED     },
+    { `STALE`,       CACHE_STALE       },
+    { `UPDATING`,    CACHE_STALE       },
+    { `BYPASS`,      CACHE_BYPASS      },
+    { `PASS`,        CACHE_BYPASS      },
+    { `DYNAMIC`,     CACHE_BYPASS      },
+    { `MISS`,        CACHE_MISS        },
+    { `HIT`,         CACHE_HIT         },
+}
+
+// Normalize a cache status value (e.g.: "HIT", "TCP_MISS", "Hit from cloudfront") into one of
+// the CACHE_* outcomes.  Where several caches are listed (e.g.: "MISS, HIT"), the last is the
+// one closest to the client, and so decides the outcome.
+//
+func NormalizeCacheStatus(value string) (string, bool) {
+    value = strings.TrimSpace(value)
+
+    if value == `` || value == `-` {
+        return ``, false
+    }
+
+    parts := strings.Split(value, `,`)
+    last := strings.ToUpper(strings.TrimSpace(parts[len(parts) - 1]))
+
+    for _, token := range CACHE_STATUS_TOKENS {
+        if strings.Contains(last, token.Token) {
+            return token.Status, true
+        }
+    }
+
+    return CACHE_OTHER, true
+}
+
+// Return whether a cache status counts as having been served from the cache.
+//
+func IsCacheHit(status string) bool {
+    switch status {
+    case CACHE_HIT, CACHE_STALE, CACHE_REVALIDATED:
+        return true
+    }
+
+    return false
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestContentClass(t *testing.T) {
+    for path, class := range map[string]string{
+        `/`:                    CONTENT_HTML,
+        `/products/42`:         CONTENT_HTML,
+        `/about.html?ref=home`: CONTENT_HTML,
+        `/img/logo.PNG`:        `image`,
+        `/static/app.js`:       `script`,
+        `/static/site.css?v=3`: `style`,
+        `/fonts/a.woff2`:       `font`,
+        `/feed.xml`:            `data`,
+        `/api/users`:           CONTENT_API,
+        `/api/users.json`:      CONTENT_API,
+        `/download/file.xyz`:   CONTENT_OTHER,
+    }{
+        if v := ContentClass(path); v != class {
+            t.Errorf("Expected '%s' to be classified as '%s', got '%s'", path, class, v)
+        }
+    }
+}
+
+func TestAddContentRule(t *testing.T) {
+    defaults := ContentRules
+    defer func() { ContentRules = defaults }()
+
+    if err := AddContentRule(`image=heic, .JXL`); err != nil {
+        t.Fatalf("Failed to add rule: %v", err)
+    }
+
+    if err := AddContentRule(`api=/graphql`); err != nil {
+        t.Fatalf("Failed to add rule: %v", err)
+    }
+
+    for path, class := range map[string]string{
+        `/photos/a.heic`: `image`,
+        `/photos/b.jxl`:  `image`,
+        `/graphql`:       CONTENT_API,
+        `/api/users`:     CONTENT_API,
+    }{
+        if v := ContentClass(path); v != class {
+            t.Errorf("Expected '%s' to be classified as '%s', got '%s'", path, class, v)
+        }
+    }
+
+    for _, spec := range []string{ `image`, `=.png`, `image=` } {
+        if err := AddContentRule(spec); err == nil {
+            t.Errorf("Expected '%s' to be rejected", spec)
+        }
+    }
+}
+
+func TestNormalizeCacheStatus(t *testing.T) {
+    for value, status := range map[string]string{
+        `HIT`:                 CACHE_HIT,
+        `TCP_MEM_HIT`:         CACHE_HIT,
+        `Hit from cloudfront`: CACHE_HIT,
+        `MISS, HIT`:           CACHE_HIT,
+        `HIT, MISS`:           CACHE_MISS,
+        `EXPIRED`:             CACHE_EXPIRED,
+        `UPDATING`:            CACHE_STALE,
+        `REVALIDATED`:         CACHE_REVALIDATED,
+        `BYPASS`:              CACHE_BYPASS,
+        `DYNAMIC`:             CACHE_BYPASS,
+        `whatever`:            CACHE_OTHER,
+    }{
+        if v, ok := NormalizeCacheStatus(value); !ok || v != status {
+            t.Errorf("Expected '%s' to be normalized as '%s', got '%s'", value, status, v)
+        }
+    }
+
+    for _, value := range []string{ ``, `-`, ` ` } {
+        if _, ok := NormalizeCacheStatus(value); ok {
+            t.Errorf("Expected '%s' to have no cache status", value)
+        }
+    }
+}
+
+func TestCacheHitRatio(t *testing.T) {
+    stat := NewLogStatistic(`test`)
+
+    if _, ok := stat.CacheHitRatio(); ok {
+        t.Errorf("Expected no hit ratio without any cache statuses")
+    }
+
+    for _, line := range []string{
+        `10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /a.png HTTP/1.1" 200 100 "-" "-" upstream_cache_status=HIT`,
+        `10.0.0.1 - - [15/Mar/2016:22:58:39 -0400] "GET /b.png HTTP/1.1" 200 100 "-" "-" upstream_cache_status=STALE`,
+        `10.0.0.1 - - [15/Mar/2016:22:58:40 -0400] "GET /c.png HTTP/1.1" 200 100 "-" "-" upstream_cache_status=MISS`,
+        `10.0.0.1 - - [15/Mar/2016:22:58:41 -0400] "GET /d.png HTTP/1.1" 200 100 "-" "-" upstream_cache_status=EXPIRED`,
+        `10.0.0.1 - - [15/Mar/2016:22:58:42 -0400] "GET /e.png HTTP/1.1" 200 100 "-" "-"`,
+    }{
+        logLine := &NcsaLog{}
+
+        if err := logLine.Parse(line); err != nil {
+            t.Fatalf("Failed to parse log line: %v", err)
+        }
+
+        stat.Add(logLine)
+    }
+
+    if stat.Caches[CACHE_HIT] != 1 || stat.Caches[CACHE_STALE] != 1 || stat.Caches[CACHE_MISS] != 1 || stat.Caches[CACHE_EXPIRED] != 1 {
+        t.Errorf("Unexpected cache statuses: %v", stat.Caches)
+    }
+
+    if ratio, ok := stat.CacheHitRatio(); !ok || ratio != 0.5 {
+        t.Errorf("Expected a hit ratio of 0.5, got %v", ratio)
+    }
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+    "math"
+    "time"
+)
+
+// An exponentially-weighted moving average of a rate (events per second), decaying over the
+// given window in the same way as the load averages reported by uptime(1).  Like those, the
+// average starts at zero and rises toward the observed rate, rather than jumping straight to
+// the first sample.
+//
+type EWMA struct {
+    Window time.Duration
+    Rate   float64
+}
+
+func NewEWMA(window time.Duration) *EWMA {
+    return &EWMA{
+        Window: window,
+    }
+}
+
+// Incorporate a count of events that occurred over the given elapsed time.
+//
+func (self *EWMA) Update(count uint64, elapsed time.Duration) {
+    if elapsed <= 0 {
+        return
+    }
+
+    instant := float64(count) / elapsed.Seconds()
+    alpha := 1 - math.Exp(-elapsed.Seconds() / self.Window.Seconds())
+
+    self.Rate += alpha * (instant - self.Rate)
+}
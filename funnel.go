@@ -0,0 +1,136 @@
+package main
+
+import (
+    "fmt"
+    "io"
+    "regexp"
+    "sort"
+    "strings"
+)
+
+const DEFAULT_TRANSITION_EDGES = 100
+
+// Separates the pages of a transition within a heavy hitters key.
+//
+const TRANSITION_SEPARATOR = "\x00"
+
+// A Funnel is an ordered series of steps through the site (e.g.: /products → /cart →
+// /checkout).  A session reaches a step once it has viewed a page matching that step after
+// reaching all of the steps before it; other pages may be viewed in between.
+//
+type Funnel struct {
+    Steps    []string
+    patterns []*regexp.Regexp
+}
+
+// Build a funnel from the given step patterns.  Each step is a regular expression matched
+// against the start of the request path (without the query string), so "/products" matches
+// "/products/42" while "/cart$" only matches "/cart".
+//
+func ParseFunnel(steps []string) (*Funnel, error) {
+    funnel := &Funnel{
+        Steps:    make([]string, 0, len(steps)),
+        patterns: make([]*regexp.Regexp, 0, len(steps)),
+    }
+
+    for _, step := range steps {
+        if step = strings.TrimSpace(step); step == `` {
+            continue
+        }
+
+        if rx, err := regexp.Compile(`^(?:` + step + `)`); err == nil {
+            funnel.Steps = append(funnel.Steps, step)
+            funnel.patterns = append(funnel.patterns, rx)
+        }else{
+            return nil, fmt.Errorf("Invalid funnel step '%s': %v", step, err)
+        }
+    }
+
+    if len(funnel.Steps) == 0 {
+        return nil, fmt.Errorf("A funnel must have at least one step")
+    }
+
+    return funnel, nil
+}
+
+// Return the progress through the funnel after viewing the given page, having already reached
+// the given number of steps.
+//
+func (self *Funnel) Advance(reached int, page string) int {
+    if self != nil && reached < len(self.patterns) && self.patterns[reached].MatchString(page) {
+        return reached + 1
+    }
+
+    return reached
+}
+
+// Counts the transitions between consecutive pages viewed within sessions.  Pages are
+// normalized into routes (see PathNormalizer) so that the graph stays readable.
+//
+type TransitionGraph struct {
+    Edges *SpaceSaving
+}
+
+func NewTransitionGraph(capacity int) *TransitionGraph {
+    return &TransitionGraph{
+        Edges: NewSpaceSaving(capacity),
+    }
+}
+
+func (self *TransitionGraph) Add(from string, to string) {
+    self.Edges.Add(PathTemplates.Template(from) + TRANSITION_SEPARATOR + PathTemplates.Template(to))
+}
+
+func (self *TransitionGraph) Merge(other *TransitionGraph) {
+    if other != nil {
+        self.Edges.Merge(other.Edges)
+    }
+}
+
+// Write the n most common transitions as a Graphviz DOT digraph, with each edge labeled by
+// its count and drawn with a weight relative to the most common transition.
+//
+func (self *TransitionGraph) WriteDOT(w io.Writer, n int) error {
+    edges := self.Edges.Top(n)
+    nodes := make(map[string]bool)
+    lines := []string{ `digraph transitions {`, `    rankdir=LR;`, `    node [shape=box];` }
+
+    for _, edge := range edges {
+        for _, node := range strings.SplitN(edge.Key, TRANSITION_SEPARATOR, 2) {
+            nodes[node] = true
+        }
+    }
+
+    names := make([]string, 0, len(nodes))
+
+    for node, _ := range nodes {
+        names = append(names, node)
+    }
+
+    sort.Strings(names)
+
+    for _, node := range names {
+        lines = append(lines, fmt.Sprintf("    %s;", dotQuote(node)))
+    }
+
+    for _, edge := range edges {
+        pages := strings.SplitN(edge.Key, TRANSITION_SEPARATOR, 2)
+
+        if len(pages) != 2 {
+            continue
+        }
+
+        width := 1.0 + 4.0 * float64(edge.Count) / float64(edges[0].Count)
+
+        lines = append(lines, fmt.Sprintf("    %s -> %s [label=\"%d\", penwidth=%.1f];", dotQuote(pages[0]), dotQuote(pages[1]), edge.Count, width))
+    }
+
+    lines = append(lines, `}`)
+
+    _, err := fmt.Fprintf(w, "%s\n", strings.Join(lines, "\n"))
+    return err
+}
+
+func dotQuote(value string) string {
+    return `"` + strings.Replace(strings.Replace(value, `\`, `\\`, -1), `"`, `\"`, -1) + `"`
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestFunnelSessions(t *testing.T) {
+    funnel, err := ParseFunnel([]string{ `/products`, `/cart$`, `/checkout` })
+
+    if err != nil {
+        t.Fatalf("Failed to parse funnel: %v", err)
+    }
+
+    stats := NewSessionStats(DEFAULT_TOPK_CAPACITY)
+    graph := NewTransitionGraph(DEFAULT_TOPK_CAPACITY)
+    tracker := NewSessionTracker(30 * time.Minute, DEFAULT_MAX_SESSIONS, stats.Add)
+    tracker.Funnel = funnel
+    tracker.OnTransition = graph.Add
+
+    for client, paths := range map[string][]string{
+        `10.0.0.1`: { `/products/1`, `/about`, `/cart`, `/checkout?step=1` },
+        `10.0.0.2`: { `/products/2`, `/cart/items`, `/products/3`, `/cart` },
+        `10.0.0.3`: { `/cart`, `/products/4`, `/cart` },
+        `10.0.0.4`: { `/checkout`, `/products/5`, `/checkout` },
+    }{
+        for i, path := range paths {
+            tracker.Add(sessionTestLine(client, time.Duration(i) * time.Second, path))
+        }
+    }
+
+    tracker.Flush()
+
+    expected := []FunnelStepRecord{
+        { Step: `/products`, Sessions: 4, Conversion: 1 },
+        { Step: `/cart$`,    Sessions: 3, Conversion: 0.75, DropOff: 1 },
+        { Step: `/checkout`, Sessions: 1, Conversion: 0.25, DropOff: 2 },
+    }
+
+    for i, record := range NewFunnelRecords(funnel, stats) {
+        if record != expected[i] {
+            t.Errorf("Expected funnel step %d to be %+v, got %+v", i, expected[i], record)
+        }
+    }
+
+    if top := graph.Edges.Top(1); len(top) != 1 || top[0].Key != "/products/:id\x00/cart" || top[0].Count != 2 {
+        t.Errorf("Expected /products/:id → /cart to be the most common transition, got %v", top)
+    }
+
+    output := bytes.NewBuffer(nil)
+
+    if err := graph.WriteDOT(output, 100); err != nil {
+        t.Fatalf("Failed to write graph: %v", err)
+    }
+
+    for _, line := range []string{
+        `digraph transitions {`,
+        `    "/products/:id" -> "/cart" [label="2", penwidth=5.0];`,
+        `    "/about" -> "/cart" [label="1", penwidth=3.0];`,
+    }{
+        if !strings.Contains(output.String(), line + "\n") {
+            t.Errorf("Expected graph to contain '%s', got:\n%s", line, output.String())
+        }
+    }
+}
+
+func TestParseFunnelInvalid(t *testing.T) {
+    for _, steps := range [][]string{ {}, { ` ` }, { `/ok`, `/bad(` } } {
+        if _, err := ParseFunnel(steps); err == nil {
+            t.Errorf("Expected an error parsing funnel %v", steps)
+        }
+    }
+}
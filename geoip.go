@@ -0,0 +1,149 @@
+package main
+
+import (
+    "net"
+    "strconv"
+)
+
+const DEFAULT_GEOIP_CACHE_SIZE = 10000
+const GEO_UNKNOWN              = `-`
+
+// The location and network owner of a client address, as found in one or more MaxMind DB
+// databases.  Fields that none of the databases provide are left empty.
+//
+type GeoLocation struct {
+    Country      string
+    Region       string
+    ASN          uint64
+    Organization string
+}
+
+// Return the autonomous system number in "AS<number>" form, or an empty string if unknown.
+//
+func (self *GeoLocation) ASNumber() string {
+    if self == nil || self.ASN == 0 {
+        return ``
+    }
+
+    return `AS` + strconv.FormatUint(self.ASN, 10)
+}
+
+// Looks up client addresses in a set of local MaxMind DB files (e.g.: GeoLite2-Country or
+// GeoLite2-City for locations, and GeoLite2-ASN for networks), caching results by address.
+//
+type GeoResolver struct {
+    Databases []*MaxMindDB
+
+    cache *LRUCache[string, *GeoLocation]
+}
+
+// The resolver used to enrich parsed log lines, or nil if no databases were given.
+//
+var GeoIP *GeoResolver
+
+func NewGeoResolver(paths []string, cacheSize int) (*GeoResolver, error) {
+    resolver := &GeoResolver{
+        Databases: make([]*MaxMindDB, 0, len(paths)),
+        cache:     NewLRUCache[string, *GeoLocation](cacheSize),
+    }
+
+    for _, path := range paths {
+        if db, err := OpenMaxMindDB(path); err == nil {
+            resolver.Databases = append(resolver.Databases, db)
+        }else{
+            return nil, err
+        }
+    }
+
+    return resolver, nil
+}
+
+// Return the location of the given address, merged from all databases.  Returns nil if the
+// address is invalid or not present in any database.
+//
+func (self *GeoResolver) Lookup(address string) *GeoLocation {
+    if self == nil {
+        return nil
+    }
+
+    if location, ok := self.cache.Get(address); ok {
+        return location
+    }
+
+    var location *GeoLocation
+
+    if ip := net.ParseIP(address); ip != nil {
+        found := GeoLocation{}
+
+        for _, db := range self.Databases {
+            if record, ok, err := db.Lookup(ip); err == nil && ok {
+                if fields, ok := record.(map[string]interface{}); ok {
+                    found.merge(fields)
+                }
+            }
+        }
+
+        if found != (GeoLocation{}) {
+            location = &found
+        }
+    }
+
+    self.cache.Put(address, location)
+
+    return location
+}
+
+func (self *GeoLocation) merge(record map[string]interface{}) {
+    if self.Country == `` {
+        for _, key := range []string{ `country`, `registered_country` } {
+            if code := mmdbLookupString(record, key, `iso_code`); code != `` {
+                self.Country = code
+                break
+            }
+        }
+    }
+
+    if self.Region == `` {
+        if subdivisions, ok := record[`subdivisions`].([]interface{}); ok && len(subdivisions) > 0 {
+            if subdivision, ok := subdivisions[0].(map[string]interface{}); ok {
+                if code := mmdbLookupString(subdivision, `iso_code`); code != `` {
+                    if self.Country != `` {
+                        self.Region = self.Country + `-` + code
+                    }else{
+                        self.Region = code
+                    }
+                }
+            }
+        }
+    }
+
+    if self.ASN == 0 {
+        if v, ok := record[`autonomous_system_number`].(uint64); ok {
+            self.ASN = v
+        }
+    }
+
+    if self.Organization == `` {
+        self.Organization = mmdbLookupString(record, `autonomous_system_organization`)
+    }
+}
+
+// Follow the given keys through nested maps, returning the string found at the end (if any).
+//
+func mmdbLookupString(record map[string]interface{}, keys ...string) string {
+    var value interface{} = record
+
+    for _, key := range keys {
+        if fields, ok := value.(map[string]interface{}); ok {
+            value = fields[key]
+        }else{
+            return ``
+        }
+    }
+
+    if s, ok := value.(string); ok {
+        return s
+    }
+
+    return ``
+}
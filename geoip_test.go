@@ -0,0 +1,104 @@
+package main
+
+import (
+    "os"
+    "path/filepath"
+    "testing"
+)
+
+func writeTestGeoDatabases(t *testing.T) []string {
+    dir := t.TempDir()
+
+    cityPath := filepath.Join(dir, `city.mmdb`)
+    asnPath := filepath.Join(dir, `asn.mmdb`)
+
+    city := buildTestMMDB(6, 24, []testMMDBEntry{
+        { `203.0.113.0/24`, map[string]interface{}{
+            `country`:      map[string]interface{}{ `iso_code`: `US` },
+            `subdivisions`: []interface{}{ map[string]interface{}{ `iso_code`: `CA` } },
+        } },
+        { `198.51.100.0/24`, map[string]interface{}{
+            `registered_country`: map[string]interface{}{ `iso_code`: `DE` },
+        } },
+    })
+
+    asn := buildTestMMDB(6, 24, []testMMDBEntry{
+        { `203.0.113.0/25`, map[string]interface{}{
+            `autonomous_system_number`:       uint64(64496),
+            `autonomous_system_organization`: `Example Networks`,
+        } },
+    })
+
+    for path, data := range map[string][]byte{ cityPath: city, asnPath: asn } {
+        if err := os.WriteFile(path, data, 0644); err != nil {
+            t.Fatalf("Failed to write test database: %v", err)
+        }
+    }
+
+    return []string{ cityPath, asnPath }
+}
+
+func TestGeoResolverLookup(t *testing.T) {
+    resolver, err := NewGeoResolver(writeTestGeoDatabases(t), 2)
+
+    if err != nil {
+        t.Fatalf("Failed to open databases: %v", err)
+    }
+
+    expected := map[string]GeoLocation{
+        `203.0.113.5`:   { Country: `US`, Region: `US-CA`, ASN: 64496, Organization: `Example Networks` },
+        `203.0.113.200`: { Country: `US`, Region: `US-CA` },
+        `198.51.100.1`:  { Country: `DE` },
+    }
+
+//  look everything up twice, so that the second pass is served (in part) from the cache
+    for i := 0; i < 2; i++ {
+        for address, location := range expected {
+            if v := resolver.Lookup(address); v == nil || *v != location {
+                t.Errorf("Expected %s to be located at %+v, got %+v", address, location, v)
+            }
+        }
+    }
+
+    for _, address := range []string{ `192.0.2.1`, `not-an-address` } {
+        if v := resolver.Lookup(address); v != nil {
+            t.Errorf("Expected %s not to be located, got %+v", address, v)
+        }
+    }
+
+    if v := (&GeoLocation{ ASN: 64496 }).ASNumber(); v != `AS64496` {
+        t.Errorf("Expected AS64496, got %s", v)
+    }
+}
+
+func TestGeoGrouping(t *testing.T) {
+    resolver, err := NewGeoResolver(writeTestGeoDatabases(t), DEFAULT_GEOIP_CACHE_SIZE)
+
+    if err != nil {
+        t.Fatalf("Failed to open databases: %v", err)
+    }
+
+    GeoIP = resolver
+    defer func(){ GeoIP = nil }()
+
+    grouping, err := ParseGrouping(`country,region,asn`)
+
+    if err != nil {
+        t.Fatalf("Failed to parse grouping: %v", err)
+    }
+
+    for line, key := range map[string]string{
+        `203.0.113.5 - - [15/Mar/2016:22:58:38 -0400] "GET / HTTP/1.1" 200 512`: `US,US-CA,AS64496`,
+        `192.0.2.1 - - [15/Mar/2016:22:58:38 -0400] "GET / HTTP/1.1" 200 512`:   `-,-,-`,
+    }{
+        logLine := NcsaLog{}
+
+        if err := logLine.Parse(line); err != nil {
+            t.Fatalf("Failed to parse log line: %v", err)
+        }
+
+        if v, ok := grouping.Key(&logLine); !ok || v != key {
+            t.Errorf("Expected key '%s', got '%s'", key, v)
+        }
+    }
+}
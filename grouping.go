@@ -0,0 +1,374 @@
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+const DEFAULT_GROUPING = `section`
+const GROUPING_SEPARATOR = `,`
+
+// Fields that may hold the virtual host a request was made to, in order of preference.
+//
+var VHOST_FIELDS = []string{ `vhost`, `server_name`, `http_host`, `host` }
+
+type keyExtractor func(*NcsaLog) (string, bool)
+
+// A Grouping determines which key each log line is aggregated under.  It is built from an
+// expression listing one or more keys (e.g.: "section", "section,method", "path:2,status").
+// A multi-key grouping joins each key's value with a comma.
+//
+// Supported keys:
+//
+//   section       the first segment of the request path (the default)
+//   path          the full request path (without the query string)
+//   path:N        the first N segments of the request path
+//   route         the request path, with IDs and other variable segments replaced by
+//                 placeholders (e.g.: "/api/:id"; see PathNormalizer)
+//   method        the HTTP method
+//   status        the HTTP status code
+//   family        the HTTP status family (e.g.: "2xx")
+//   protocol      the HTTP protocol version
+//   host          the address of the peer that connected to the server; behind a proxy or load
+//                 balancer this is the proxy's address, so per-client groupings should use
+//                 "client" instead
+//   client        the address of the client that made the request, taken from the
+//                 forwarded-for chain when the peer is a trusted proxy (see ResolveClientAddress)
+//   network:V4/V6 the network containing the client address, aggregated to the given IPv4 and
+//                 IPv6 prefix lengths (default: "network:24/64")
+//   country       the ISO country code of the client address (requires a GeoIP database);
+//                 addresses that cannot be located are grouped under "-"
+//   region        the ISO subdivision code (e.g.: "US-CA") of the client address
+//   asn           the autonomous system number (e.g.: "AS64496") of the client address
+//   class         the content class of the request (e.g.: "image", "script", "html", "api";
+//                 see ContentClass)
+//   cache         the cache status of the response (e.g.: "hit", "miss", "expired")
+//   vhost         the virtual host, from a "vhost", "server_name", "http_host" or "host" field
+//   user          the authenticated user
+//   useragent     the browser, bot or tool that made the request (see UserAgentClassifier)
+//   browser       the browser family (e.g.: "Firefox"); "browser:version" adds the major
+//                 version (e.g.: "Firefox 115")
+//   os            the operating system (e.g.: "Android"); "os:version" adds the major version
+//   device        the device type: "desktop", "mobile", "tablet", "tv", "console" or "bot"
+//   bot           whether the request was made by a known bot or tool ("bot") or not ("human")
+//   field:NAME    any extra "key=value" field from a custom log format
+//   regex:EXPR    the first capture group of a regular expression matched against the path;
+//                 since the expression may itself contain commas, this must be the last key
+//
+type Grouping struct {
+    Expression string
+
+    extractors []keyExtractor
+}
+
+func ParseGrouping(expression string) (*Grouping, error) {
+    grouping := &Grouping{
+        Expression: expression,
+        extractors: make([]keyExtractor, 0),
+    }
+
+    remaining := strings.TrimSpace(expression)
+
+    for remaining != `` {
+        var key string
+
+        if strings.HasPrefix(remaining, `regex:`) {
+            key = remaining
+            remaining = ``
+        }else if parts := strings.SplitN(remaining, GROUPING_SEPARATOR, 2); len(parts) == 2 {
+            key = strings.TrimSpace(parts[0])
+            remaining = strings.TrimSpace(parts[1])
+        }else{
+            key = strings.TrimSpace(parts[0])
+            remaining = ``
+        }
+
+        if extractor, err := parseKey(key); err == nil {
+            grouping.extractors = append(grouping.extractors, extractor)
+        }else{
+            return nil, err
+        }
+    }
+
+    if len(grouping.extractors) == 0 {
+        return nil, fmt.Errorf("Grouping expression must contain at least one key")
+    }
+
+    return grouping, nil
+}
+
+// Return the grouping key for the given log line, with any RedactedParams redacted from each
+// part (see RedactValue).  If any part of the key cannot be determined, the line is not
+// grouped and false is returned.
+//
+func (self *Grouping) Key(logLine *NcsaLog) (string, bool) {
+    values := make([]string, len(self.extractors))
+
+    for i, extractor := range self.extractors {
+        if value, ok := extractor(logLine); ok {
+            values[i] = RedactValue(value)
+        }else{
+            return ``, false
+        }
+    }
+
+    return strings.Join(values, GROUPING_SEPARATOR), true
+}
+
+func parseKey(key string) (keyExtractor, error) {
+    name := key
+    arg := ``
+
+    if parts := strings.SplitN(key, `:`, 2); len(parts) == 2 {
+        name = parts[0]
+        arg = parts[1]
+    }
+
+    switch name {
+    case `section`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return PathSection(logLine.Path)
+        }, nil
+
+    case `path`:
+        if arg == `` {
+            return func(logLine *NcsaLog) (string, bool) {
+                return RequestPath(logLine.Path), true
+            }, nil
+        }
+
+        if depth, err := strconv.Atoi(arg); err == nil && depth > 0 {
+            return func(logLine *NcsaLog) (string, bool) {
+                segments := PathSegments(logLine.Path)
+
+                if len(segments) > depth {
+                    segments = segments[:depth]
+                }
+
+                return `/` + strings.Join(segments, `/`), true
+            }, nil
+        }else{
+            return nil, fmt.Errorf("Invalid path depth '%s'", arg)
+        }
+
+    case `route`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return PathTemplates.Template(logLine.Path), true
+        }, nil
+
+    case `method`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return logLine.Method, logLine.Method != ``
+        }, nil
+
+    case `status`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return strconv.FormatUint(uint64(logLine.StatusCode), 10), true
+        }, nil
+
+    case `family`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return StatusFamily(logLine.StatusCode), true
+        }, nil
+
+    case `protocol`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return logLine.Protocol, logLine.Protocol != ``
+        }, nil
+
+    case `host`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return logLine.Host, logLine.Host != ``
+        }, nil
+
+    case `client`:
+        return func(logLine *NcsaLog) (string, bool) {
+            address := logLine.ClientAddress()
+            return address, address != ``
+        }, nil
+
+    case `network`:
+        ipv4Bits := DEFAULT_IPV4_PREFIX
+        ipv6Bits := DEFAULT_IPV6_PREFIX
+
+        if arg != `` {
+            prefixes := strings.SplitN(arg, `/`, 2)
+
+            if v, err := strconv.Atoi(prefixes[0]); err == nil && v >= 0 && v <= 32 {
+                ipv4Bits = v
+            }else{
+                return nil, fmt.Errorf("Invalid IPv4 prefix length '%s'", prefixes[0])
+            }
+
+            if len(prefixes) == 2 {
+                if v, err := strconv.Atoi(prefixes[1]); err == nil && v >= 0 && v <= 128 {
+                    ipv6Bits = v
+                }else{
+                    return nil, fmt.Errorf("Invalid IPv6 prefix length '%s'", prefixes[1])
+                }
+            }
+        }
+
+        return func(logLine *NcsaLog) (string, bool) {
+            return NetworkPrefix(logLine.ClientAddress(), ipv4Bits, ipv6Bits)
+        }, nil
+
+    case `country`:
+        return func(logLine *NcsaLog) (string, bool) {
+            if logLine.Geo != nil && logLine.Geo.Country != `` {
+                return logLine.Geo.Country, true
+            }
+
+            return GEO_UNKNOWN, true
+        }, nil
+
+    case `region`:
+        return func(logLine *NcsaLog) (string, bool) {
+            if logLine.Geo != nil && logLine.Geo.Region != `` {
+                return logLine.Geo.Region, true
+            }
+
+            return GEO_UNKNOWN, true
+        }, nil
+
+    case `asn`:
+        return func(logLine *NcsaLog) (string, bool) {
+            if asn := logLine.Geo.ASNumber(); asn != `` {
+                return asn, true
+            }
+
+            return GEO_UNKNOWN, true
+        }, nil
+
+    case `class`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return ContentClass(logLine.Path), true
+        }, nil
+
+    case `cache`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return logLine.CacheStatus()
+        }, nil
+
+    case `vhost`:
+        return func(logLine *NcsaLog) (string, bool) {
+            for _, field := range VHOST_FIELDS {
+                if value, ok := logLine.Fields[field]; ok && value != `` && value != `-` {
+                    return value, true
+                }
+            }
+
+            return ``, false
+        }, nil
+
+    case `user`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return logLine.UserId, logLine.UserId != ``
+        }, nil
+
+    case `useragent`, `ua`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return logLine.Agent().Family(false), true
+        }, nil
+
+    case `browser`, `os`:
+        withVersion := false
+
+        switch arg {
+        case ``:
+        case `version`:
+            withVersion = true
+        default:
+            return nil, fmt.Errorf("Invalid argument '%s' for key '%s' (expected '%s:version')", arg, name, name)
+        }
+
+        if name == `os` {
+            return func(logLine *NcsaLog) (string, bool) {
+                return logLine.Agent().Platform(withVersion), true
+            }, nil
+        }
+
+        return func(logLine *NcsaLog) (string, bool) {
+            if agent := logLine.Agent(); agent.Bot {
+                return agent.Browser, true
+            }else{
+                return agent.Family(withVersion), true
+            }
+        }, nil
+
+    case `device`:
+        return func(logLine *NcsaLog) (string, bool) {
+            return logLine.Agent().Device, true
+        }, nil
+
+    case `bot`:
+        return func(logLine *NcsaLog) (string, bool) {
+            if logLine.Agent().Bot {
+                return `bot`, true
+            }
+
+            return `human`, true
+        }, nil
+
+    case `field`:
+        if arg == `` {
+            return nil, fmt.Errorf("Field key must specify a field name (e.g.: 'field:upstream')")
+        }
+
+        return func(logLine *NcsaLog) (string, bool) {
+            value, ok := logLine.Fields[arg]
+            return value, ok
+        }, nil
+
+    case `regex`:
+        if rx, err := regexp.Compile(arg); err == nil {
+            if rx.NumSubexp() < 1 {
+                return nil, fmt.Errorf("Regular expression '%s' must contain a capture group", arg)
+            }
+
+            return func(logLine *NcsaLog) (string, bool) {
+                if match := rx.FindStringSubmatch(logLine.Path); match != nil {
+                    return match[1], true
+                }
+
+                return ``, false
+            }, nil
+        }else{
+            return nil, fmt.Errorf("Invalid regular expression '%s': %v", arg, err)
+        }
+    }
+
+    return nil, fmt.Errorf("Unknown grouping key '%s'", key)
+}
+
+// Return the site section (the first segment) of the given request path.
+//
+func PathSection(path string) (string, bool) {
+    if parts := strings.Split(path, `/`); len(parts) > 1 {
+        return strings.Split(parts[1], `?`)[0], true
+    }
+
+    return ``, false
+}
+
+// Return the request path with any query string removed.
+//
+func RequestPath(path string) string {
+    return strings.SplitN(path, `?`, 2)[0]
+}
+
+// Return the non-empty segments of the request path (not including the query string).
+//
+func PathSegments(path string) []string {
+    segments := make([]string, 0)
+
+    for _, segment := range strings.Split(RequestPath(path), `/`) {
+        if segment != `` {
+            segments = append(segments, segment)
+        }
+    }
+
+    return segments
+}
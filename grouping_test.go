@@ -0,0 +1,78 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestGroupingKeys(t *testing.T) {
+    logLine := NcsaLog{}
+
+    if err := logLine.Parse(`10.0.0.1 - alice [15/Mar/2016:22:58:38 -0400] "GET /api/v1/users/42?page=2 HTTP/1.1" 404 512 "http://example.com/" "Mozilla/5.0 (X11; Linux x86_64) Chrome/50.0 Safari/537.36" vhost=www.example.com upstream=10.0.0.2`); err != nil {
+        t.Fatalf("Failed to parse log line: %v", err)
+    }
+
+    expected := map[string]string{
+        `section`:                   `api`,
+        `path`:                      `/api/v1/users/42`,
+        `path:2`:                    `/api/v1`,
+        `path:10`:                   `/api/v1/users/42`,
+        `method`:                    `GET`,
+        `status`:                    `404`,
+        `family`:                    `4xx`,
+        `protocol`:                  `HTTP/1.1`,
+        `host`:                      `10.0.0.1`,
+        `client`:                    `10.0.0.1`,
+        `network`:                   `10.0.0.0/24`,
+        `network:16`:                `10.0.0.0/16`,
+        `vhost`:                     `www.example.com`,
+        `user`:                      `alice`,
+        `useragent`:                 `Chrome`,
+        `browser:version`:           `Chrome 50`,
+        `os`:                        `Linux`,
+        `device`:                    `desktop`,
+        `bot`:                       `human`,
+        `section,bot`:               `api,human`,
+        `field:upstream`:            `10.0.0.2`,
+        `section,method`:            `api,GET`,
+        `status,regex:/users/(\d+)`: `404,42`,
+        `regex:^/api/(v\d+)/`:       `v1`,
+    }
+
+    for expression, value := range expected {
+        if grouping, err := ParseGrouping(expression); err == nil {
+            if key, ok := grouping.Key(&logLine); !ok {
+                t.Errorf("Grouping '%s' did not produce a key", expression)
+            }else if key != value {
+                t.Errorf("Grouping '%s': expected '%s', got '%s'", expression, value, key)
+            }
+        }else{
+            t.Errorf("Failed to parse grouping '%s': %v", expression, err)
+        }
+    }
+
+    if logLine.Referer != `http://example.com/` {
+        t.Errorf("Expected referer to be parsed, got '%s'", logLine.Referer)
+    }
+}
+
+func TestGroupingMissingKeys(t *testing.T) {
+    logLine := NcsaLog{
+        Path: `/api/1`,
+    }
+
+    for _, expression := range []string{ `field:missing`, `vhost`, `section,regex:^/img/(.*)` } {
+        if grouping, err := ParseGrouping(expression); err == nil {
+            if _, ok := grouping.Key(&logLine); ok {
+                t.Errorf("Grouping '%s' should not have produced a key", expression)
+            }
+        }else{
+            t.Errorf("Failed to parse grouping '%s': %v", expression, err)
+        }
+    }
+
+    for _, expression := range []string{ ``, `bogus`, `path:0`, `regex:nocapture`, `field:`, `network:33`, `network:24/129`, `browser:major` } {
+        if _, err := ParseGrouping(expression); err == nil {
+            t.Errorf("Expected grouping '%s' to be rejected", expression)
+        }
+    }
+}
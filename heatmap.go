@@ -0,0 +1,146 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "time"
+)
+
+const HEATMAP_TOTAL   = `total`
+const HEATMAP_SECTION = `section`
+const HEATMAP_STATUS  = `status`
+
+// The most distinct sections (or status classes) given their own heatmap; requests for any
+// others are counted under HEATMAP_OTHER.
+//
+const DEFAULT_HEATMAP_KEYS = 20
+const HEATMAP_OTHER        = `(other)`
+
+// The days of the week in the order they are displayed (Monday first).
+//
+var HEATMAP_WEEKDAYS = []time.Weekday{
+    time.Monday,
+    time.Tuesday,
+    time.Wednesday,
+    time.Thursday,
+    time.Friday,
+    time.Saturday,
+    time.Sunday,
+}
+
+// Request counts by day of the week (indexed as HEATMAP_WEEKDAYS) and hour of the day.
+//
+type HeatmapMatrix [7][24]uint64
+
+func (self *HeatmapMatrix) Total() uint64 {
+    var total uint64
+
+    for _, hours := range self {
+        for _, count := range hours {
+            total += count
+        }
+    }
+
+    return total
+}
+
+func (self *HeatmapMatrix) Max() uint64 {
+    var max uint64
+
+    for _, hours := range self {
+        for _, count := range hours {
+            if count > max {
+                max = count
+            }
+        }
+    }
+
+    return max
+}
+
+// Counts requests by hour of the day and day of the week over an entire run (rather than per
+// interval), either for all requests or broken down by section or status class.  Requests on
+// the same weekday of different weeks are added together.
+//
+type Heatmap struct {
+    By       string
+    Location *time.Location
+    MaxKeys  int
+    Matrices map[string]*HeatmapMatrix
+}
+
+func NewHeatmap(by string, location *time.Location) (*Heatmap, error) {
+    switch by {
+    case HEATMAP_TOTAL, HEATMAP_SECTION, HEATMAP_STATUS:
+    default:
+        return nil, fmt.Errorf("Invalid heatmap '%s': must be one of total, section or status", by)
+    }
+
+    return &Heatmap{
+        By:       by,
+        Location: location,
+        MaxKeys:  DEFAULT_HEATMAP_KEYS,
+        Matrices: make(map[string]*HeatmapMatrix),
+    }, nil
+}
+
+// Record a request, which was grouped into the given section.
+//
+func (self *Heatmap) Add(section string, logLine *NcsaLog) {
+    var key string
+
+    switch self.By {
+    case HEATMAP_SECTION:
+        key = section
+    case HEATMAP_STATUS:
+        key = StatusFamily(logLine.StatusCode)
+    default:
+        key = HEATMAP_TOTAL
+    }
+
+    matrix, ok := self.Matrices[key]
+
+    if !ok {
+        if len(self.Matrices) >= self.MaxKeys {
+            key = HEATMAP_OTHER
+        }
+
+        if matrix, ok = self.Matrices[key]; !ok {
+            matrix = &HeatmapMatrix{}
+            self.Matrices[key] = matrix
+        }
+    }
+
+    timestamp := logLine.Timestamp.In(self.Location)
+    day := (int(timestamp.Weekday()) + 6) % 7
+
+    matrix[day][timestamp.Hour()] += 1
+}
+
+// Return the keys of the heatmap, busiest first (with any HEATMAP_OTHER requests last).
+//
+func (self *Heatmap) Keys() []string {
+    keys := make([]string, 0, len(self.Matrices))
+
+    for key, _ := range self.Matrices {
+        if key != HEATMAP_OTHER {
+            keys = append(keys, key)
+        }
+    }
+
+    sort.Slice(keys, func(i, j int) bool {
+        ti, tj := self.Matrices[keys[i]].Total(), self.Matrices[keys[j]].Total()
+
+        if ti == tj {
+            return keys[i] < keys[j]
+        }
+
+        return ti > tj
+    })
+
+    if _, ok := self.Matrices[HEATMAP_OTHER]; ok {
+        keys = append(keys, HEATMAP_OTHER)
+    }
+
+    return keys
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "time"
+)
+
+func TestHeatmap(t *testing.T) {
+    location, err := time.LoadLocation(`America/New_York`)
+
+    if err != nil {
+        t.Skipf("Timezone data unavailable: %v", err)
+    }
+
+    heatmap, err := NewHeatmap(HEATMAP_STATUS, location)
+
+    if err != nil {
+        t.Fatalf("Failed to create heatmap: %v", err)
+    }
+
+    for _, line := range []struct{
+        Timestamp time.Time
+        Status    uint
+    }{
+    //  Monday 02:30 UTC is Sunday 22:30 in New York
+        { time.Date(2024, time.March, 18, 2, 30, 0, 0, time.UTC), 200 },
+        { time.Date(2024, time.March, 18, 2, 45, 0, 0, time.UTC), 200 },
+        { time.Date(2024, time.March, 25, 2, 15, 0, 0, time.UTC), 200 },
+        { time.Date(2024, time.March, 20, 15, 0, 0, 0, time.UTC), 503 },
+    }{
+        heatmap.Add(`/`, &NcsaLog{ Timestamp: line.Timestamp, StatusCode: line.Status })
+    }
+
+    if keys := heatmap.Keys(); strings.Join(keys, `,`) != `2xx,5xx` {
+        t.Errorf("Expected keys 2xx,5xx, got %v", keys)
+    }
+
+    if v := heatmap.Matrices[`2xx`][6][22]; v != 3 {
+        t.Errorf("Expected 3 requests on Sunday at 22:00, got %d", v)
+    }
+
+    if v := heatmap.Matrices[`5xx`][2][11]; v != 1 {
+        t.Errorf("Expected 1 request on Wednesday at 11:00, got %d", v)
+    }
+
+    if total, max := heatmap.Matrices[`2xx`].Total(), heatmap.Matrices[`2xx`].Max(); total != 3 || max != 3 {
+        t.Errorf("Expected a total and max of 3, got %d and %d", total, max)
+    }
+
+    var buffer bytes.Buffer
+
+    if err := WriteHeatmapCSV(&buffer, heatmap); err != nil {
+        t.Fatalf("Failed to write CSV: %v", err)
+    }
+
+    if lines := strings.Split(strings.TrimSpace(buffer.String()), "\n"); len(lines) != 15 || lines[7] != `2xx,Sun,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,0,3,0` {
+        t.Errorf("Unexpected CSV output:\n%s", buffer.String())
+    }
+}
+
+func TestHeatmapMaxKeys(t *testing.T) {
+    heatmap, _ := NewHeatmap(HEATMAP_SECTION, time.UTC)
+    heatmap.MaxKeys = 2
+
+    for _, section := range []string{ `/a`, `/b`, `/c`, `/d`, `/a` } {
+        heatmap.Add(section, &NcsaLog{ Timestamp: time.Now() })
+    }
+
+    if keys := heatmap.Keys(); strings.Join(keys, `,`) != `/a,/b,` + HEATMAP_OTHER {
+        t.Errorf("Expected keys /a,/b,%s, got %v", HEATMAP_OTHER, keys)
+    }
+
+    if v := heatmap.Matrices[HEATMAP_OTHER].Total(); v != 2 {
+        t.Errorf("Expected 2 other requests, got %d", v)
+    }
+
+    if _, err := NewHeatmap(`bogus`, time.UTC); err == nil {
+        t.Errorf("Expected an invalid heatmap to be rejected")
+    }
+}
+
+func TestFormatCount(t *testing.T) {
+    for count, formatted := range map[uint64]string{
+        0:          `0`,
+        950:        `950`,
+        9999:       `9999`,
+        12345:      `12k`,
+        999600:     `1.0M`,
+        9500000:    `9.5M`,
+        2000000000: `2.0G`,
+    }{
+        if v := formatCount(count); v != formatted {
+            t.Errorf("Expected %d to be formatted as '%s', got '%s'", count, formatted, v)
+        }
+    }
+}
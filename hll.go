@@ -0,0 +1,143 @@
+package main
+
+import (
+    "hash/fnv"
+    "math"
+    "math/bits"
+)
+
+const DEFAULT_HLL_PRECISION = 12
+
+// A HyperLogLog sketch estimates the number of distinct values it has seen using a fixed
+// amount of memory (2^Precision one-byte registers, for a standard error of about
+// 1.04/sqrt(2^Precision)).  Sketches of the same precision can be merged, yielding the
+// distinct count of the union of their inputs.  Small sketches keep the full hash of each
+// value (giving exact counts) until they grow past a fraction of the dense register size.
+//
+type HyperLogLog struct {
+    Precision uint8
+
+    registers []uint8
+    sparse    map[uint64]struct{}
+}
+
+func NewHyperLogLog() *HyperLogLog {
+    return NewHyperLogLogWithPrecision(DEFAULT_HLL_PRECISION)
+}
+
+func NewHyperLogLogWithPrecision(precision uint8) *HyperLogLog {
+    return &HyperLogLog{
+        Precision: precision,
+        sparse:    make(map[uint64]struct{}),
+    }
+}
+
+// Record a value.
+//
+func (self *HyperLogLog) Add(value string) {
+    hasher := fnv.New64a()
+    hasher.Write([]byte(value))
+
+    self.addHash(mix64(hasher.Sum64()))
+}
+
+// Accumulate another sketch (of the same precision) into this one.
+//
+func (self *HyperLogLog) Merge(other *HyperLogLog) {
+    if other == nil || other.Precision != self.Precision {
+        return
+    }
+
+    if other.registers != nil {
+        self.densify()
+
+        for idx, rank := range other.registers {
+            if rank > self.registers[idx] {
+                self.registers[idx] = rank
+            }
+        }
+    }else{
+        for hash := range other.sparse {
+            self.addHash(hash)
+        }
+    }
+}
+
+// Return the estimated number of distinct values seen (none, for a nil sketch).
+//
+func (self *HyperLogLog) Count() uint64 {
+    if self == nil {
+        return 0
+    }
+
+    if self.registers == nil {
+        return uint64(len(self.sparse))
+    }
+
+    m := float64(uint64(1) << self.Precision)
+
+    var sum float64
+    var zeroes float64
+
+    for _, rank := range self.registers {
+        sum += math.Ldexp(1, -int(rank))
+
+        if rank == 0 {
+            zeroes += 1
+        }
+    }
+
+    estimate := (0.7213 / (1 + 1.079 / m)) * m * m / sum
+
+//  use linear counting for small cardinalities, where it is more accurate
+    if estimate <= 2.5 * m && zeroes > 0 {
+        estimate = m * math.Log(m / zeroes)
+    }
+
+    return uint64(estimate + 0.5)
+}
+
+func (self *HyperLogLog) addHash(hash uint64) {
+    if self.registers == nil {
+        self.sparse[hash] = struct{}{}
+
+    //  switch to dense registers once there are too many hashes to keep around
+        if len(self.sparse) > (1 << self.Precision) / 8 {
+            self.densify()
+        }
+
+        return
+    }
+
+    idx := hash >> (64 - self.Precision)
+    rank := uint8(bits.LeadingZeros64((hash << self.Precision) | (1 << (self.Precision - 1)))) + 1
+
+    if rank > self.registers[idx] {
+        self.registers[idx] = rank
+    }
+}
+
+func (self *HyperLogLog) densify() {
+    if self.registers != nil {
+        return
+    }
+
+    self.registers = make([]uint8, 1 << self.Precision)
+
+    for hash := range self.sparse {
+        self.addHash(hash)
+    }
+
+    self.sparse = nil
+}
+
+// A 64-bit finalizer (from SplitMix64) to spread FNV hashes evenly across all bits.
+//
+func mix64(x uint64) uint64 {
+    x ^= x >> 30
+    x *= 0xbf58476d1ce4e5b9
+    x ^= x >> 27
+    x *= 0x94d049bb133111eb
+    x ^= x >> 31
+    return x
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "fmt"
+    "math"
+    "testing"
+)
+
+func assertCardinality(t *testing.T, label string, expected int, hll *HyperLogLog) {
+    actual := float64(hll.Count())
+
+    if math.Abs(actual - float64(expected)) > float64(expected) * 0.05 {
+        t.Errorf("%s: expected ~%d distinct values, got %.0f", label, expected, actual)
+    }
+}
+
+func TestHyperLogLogSmall(t *testing.T) {
+    hll := NewHyperLogLog()
+
+    if hll.Count() != 0 {
+        t.Errorf("Expected an empty sketch to count 0, got %d", hll.Count())
+    }
+
+    for i := 0; i < 3; i++ {
+        hll.Add(`10.0.0.1`)
+        hll.Add(`10.0.0.2`)
+    }
+
+    if hll.Count() != 2 {
+        t.Errorf("Expected 2 distinct values, got %d", hll.Count())
+    }
+}
+
+func TestHyperLogLogLarge(t *testing.T) {
+    hll := NewHyperLogLog()
+
+    for i := 0; i < 100000; i++ {
+        hll.Add(fmt.Sprintf("10.%d.%d.%d", i >> 16, (i >> 8) & 0xff, i & 0xff))
+    }
+
+    assertCardinality(t, `large`, 100000, hll)
+}
+
+func TestHyperLogLogMerge(t *testing.T) {
+    a := NewHyperLogLog()
+    b := NewHyperLogLog()
+
+    for i := 0; i < 20000; i++ {
+        a.Add(fmt.Sprintf("value-%d", i))
+        b.Add(fmt.Sprintf("value-%d", i + 10000))
+    }
+
+//  merging a sparse sketch into a dense one (and vice versa) must also work
+    small := NewHyperLogLog()
+    small.Add(`value-1`)
+    small.Add(`extra`)
+
+    a.Merge(b)
+    a.Merge(small)
+
+    assertCardinality(t, `merged`, 30001, a)
+
+    small.Merge(b)
+    assertCardinality(t, `merged into sparse`, 20002, small)
+}
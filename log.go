@@ -8,37 +8,256 @@ import (
     "strconv"
     "strings"
     "time"
+
+    log "github.com/Sirupsen/logrus"
 )
 
-const NCSA_RX               = `^(?P<host>(?:\d{1,3}[\.]){3}\d{1,3}) (?P<id>\S+) (?P<user>\S+) \[(?P<timestamp>[^\]]+)\] "(?P<method>\S+) (?P<path>\S+) (?P<protocol>[^"]+)" (?P<status>\d+) (?P<size>\d+) ?(?P<rest>.*)`
-const NCSA_TIMESTAMP_LAYOUT = `2/Jan/2006:15:04:05 -0700`
+const NCSA_RX               = `^(?P<host>\S+) (?P<id>\S+) (?P<user>\S+) \[(?P<timestamp>[^\]]+)\] "(?P<method>\S+) (?P<path>\S+) (?P<protocol>[^"]+)" (?P<status>\d+) (?P<size>\d+) ?(?P<rest>.*)`
+
+// Timestamp layouts that will be tried (in order) when parsing the timestamp field of a log line.
+// Numeric timestamps (epoch seconds or milliseconds) are handled separately by ParseTimestamp.
+//
+var NCSA_TIMESTAMP_LAYOUTS = []string{
+    `2/Jan/2006:15:04:05 -0700`,
+    `2/Jan/2006:15:04:05.000 -0700`,
+    time.RFC3339Nano,
+    `2006-01-02T15:04:05.999999999-0700`,
+    `2006-01-02T15:04:05.999999999`,
+    `2006-01-02 15:04:05.999999999 -0700`,
+    `2006-01-02 15:04:05.999999999`,
+}
+
+// All parsed timestamps are normalized to this location so that logs from servers
+// in different timezones can be summarized together.
+//
+var TimestampLocation = time.Local
+
+var epochRx = regexp.MustCompile(`^(\d+)(?:\.(\d+))?$`)
+
+// Extra fields appended to the end of a log line in "key=value" form (as is common with custom
+// nginx and Apache log formats) whose value is the time taken to serve the request, along with
+// the unit that value is expressed in.
+//
+var DURATION_FIELDS = map[string]time.Duration{
+    `request_time`: time.Second,      // nginx $request_time
+    `rt`:           time.Second,
+    `T`:            time.Second,      // Apache %T
+    `D`:            time.Microsecond, // Apache %D
+    `duration_us`:  time.Microsecond,
+    `duration_ms`:  time.Millisecond,
+}
+
+var restFieldRx = regexp.MustCompile(`(?:([\w\-\.]+)=)?(?:"((?:[^"\\]|\\.)*)"|(\S+))`)
 
+// Counts and sketches of the requests in one section (or of all requests).  The sketches and
+// breakdowns are only allocated once something is recorded in them, so a statistic costs
+// little until it sees requests, and breakdowns that never see a value (e.g.: durations,
+// countries or cache statuses, when the logs have none) cost nothing at all.
+//
 type LogStatistic struct {
-    Key     string
-    Count   uint64
-    Sizes   []uint64
-    Logs    []*NcsaLog
+    Key       string
+    Count     uint64
+    Bytes     uint64
+    First     time.Time
+    Last      time.Time
+    Sizes     *QuantileSketch
+    Durations *QuantileSketch
+    Statuses  map[uint]uint64
+    Methods   map[string]uint64
+    Protocols map[string]uint64
+    Countries map[string]uint64
+    ASNs      map[string]uint64
+    Caches    map[string]uint64
+    Hosts     *HyperLogLog
+    Clients   *HyperLogLog
+    Paths     *HyperLogLog
+    Sections  *HyperLogLog
 }
 
 func NewLogStatistic(key string) *LogStatistic {
     return &LogStatistic{
-        Key:     key,
-        Count:   0,
-        Sizes:   make([]uint64, 0),
-        Logs:    make([]*NcsaLog, 0),
+        Key:   key,
+        Count: 0,
+    }
+}
+
+// Record a single log line in this statistic.
+//
+func (self *LogStatistic) Add(logLine *NcsaLog) {
+    self.Count += 1
+    self.Bytes += logLine.Size
+    self.observeTime(logLine.Timestamp, logLine.Timestamp)
+    self.allocate()
+    self.Sizes.Add(float64(logLine.Size))
+    addCount(&self.Statuses, logLine.StatusCode, 1)
+    addCount(&self.Methods, logLine.Method, 1)
+    addCount(&self.Protocols, logLine.Protocol, 1)
+    self.Hosts.Add(logLine.ClientAddress())
+    self.Clients.Add(logLine.ClientAddress() + "\x00" + logLine.UserAgent)
+    self.Paths.Add(RequestPath(logLine.Path))
+
+    if section, ok := PathSection(logLine.Path); ok {
+        self.Sections.Add(section)
+    }
+
+    if logLine.HasDuration {
+        if self.Durations == nil {
+            self.Durations = NewQuantileSketch()
+        }
+
+        self.Durations.Add(logLine.Duration.Seconds())
+    }
+
+    if status, ok := logLine.CacheStatus(); ok {
+        addCount(&self.Caches, status, 1)
+    }
+
+    if logLine.Geo != nil {
+        if logLine.Geo.Country != `` {
+            addCount(&self.Countries, logLine.Geo.Country, 1)
+        }
+
+        if asn := logLine.Geo.ASNumber(); asn != `` {
+            addCount(&self.ASNs, asn, 1)
+        }
     }
 }
 
-func (self *LogStatistic) AvarageSize() float64 {
-    var sz uint64
+// Accumulate the values of another statistic into this one.
+//
+func (self *LogStatistic) Merge(other *LogStatistic) {
+    self.Count += other.Count
+    self.Bytes += other.Bytes
+    self.observeTime(other.First, other.Last)
+
+    if other.Sizes != nil {
+        self.allocate()
+        self.Sizes.Merge(other.Sizes)
+        self.Hosts.Merge(other.Hosts)
+        self.Clients.Merge(other.Clients)
+        self.Paths.Merge(other.Paths)
+        self.Sections.Merge(other.Sections)
+    }
+
+    if other.Durations != nil {
+        if self.Durations == nil {
+            self.Durations = NewQuantileSketch()
+        }
+
+        self.Durations.Merge(other.Durations)
+    }
+
+    for code, count := range other.Statuses {
+        addCount(&self.Statuses, code, count)
+    }
+
+    for method, count := range other.Methods {
+        addCount(&self.Methods, method, count)
+    }
+
+    for protocol, count := range other.Protocols {
+        addCount(&self.Protocols, protocol, count)
+    }
+
+    for country, count := range other.Countries {
+        addCount(&self.Countries, country, count)
+    }
+
+    for asn, count := range other.ASNs {
+        addCount(&self.ASNs, asn, count)
+    }
+
+    for status, count := range other.Caches {
+        addCount(&self.Caches, status, count)
+    }
+}
+
+// allocate the sketches that every request is recorded in
+func (self *LogStatistic) allocate() {
+    if self.Sizes != nil {
+        return
+    }
+
+    self.Sizes = NewQuantileSketch()
+    self.Hosts = NewHyperLogLog()
+    self.Clients = NewHyperLogLog()
+    self.Paths = NewHyperLogLog()
+    self.Sections = NewHyperLogLog()
+}
 
-    for _, s := range self.Sizes {
-        sz += s
+// add to a count in a breakdown, creating the breakdown if necessary
+func addCount[K comparable](counts *map[K]uint64, key K, count uint64) {
+    if *counts == nil {
+        *counts = make(map[K]uint64)
     }
 
-    return float64(sz / uint64(len(self.Sizes)))
+    (*counts)[key] += count
 }
 
+// Return the share of responses with a known cache status that were served from the cache
+// (and whether any responses had a cache status at all).
+//
+func (self *LogStatistic) CacheHitRatio() (float64, bool) {
+    var hits, total uint64
+
+    for status, count := range self.Caches {
+        total += count
+
+        if IsCacheHit(status) {
+            hits += count
+        }
+    }
+
+    if total == 0 {
+        return 0, false
+    }
+
+    return float64(hits) / float64(total), true
+}
+
+// Return the span of time between the earliest and latest log timestamps seen.
+//
+func (self *LogStatistic) TimeSpan() time.Duration {
+    return self.Last.Sub(self.First)
+}
+
+func (self *LogStatistic) observeTime(first time.Time, last time.Time) {
+    if !first.IsZero() && (self.First.IsZero() || first.Before(self.First)) {
+        self.First = first
+    }
+
+    if !last.IsZero() && (self.Last.IsZero() || last.After(self.Last)) {
+        self.Last = last
+    }
+}
+
+// Return the mean response size in bytes (or zero if there were no responses).
+//
+func (self *LogStatistic) AverageSize() float64 {
+    if self.Sizes == nil {
+        return 0
+    }
+
+    return self.Sizes.Mean()
+}
+
+// Return the fraction of responses that had a client or server error (4xx or 5xx) status.
+//
+func (self *LogStatistic) ErrorRatio() float64 {
+    var errors uint64
+
+    if self.Count == 0 {
+        return 0
+    }
+
+    for code, count := range self.Statuses {
+        if code >= 400 && code < 600 {
+            errors += count
+        }
+    }
+
+    return float64(errors) / float64(self.Count)
+}
 
 func (self *LogStatistic) GroupByStatusFamily() map[string]uint64 {
     statuses := map[string]uint64{
@@ -50,50 +269,142 @@ func (self *LogStatistic) GroupByStatusFamily() map[string]uint64 {
         `???`: 0,
     }
 
-    for _, logLine := range self.Logs {
-        if logLine.StatusCode < 200 {
-            if v, ok := statuses[`1xx`]; ok {
-                statuses[`1xx`] = (v + 1)
-            }
-        }else if logLine.StatusCode < 300 {
-            if v, ok := statuses[`2xx`]; ok {
-                statuses[`2xx`] = (v + 1)
-            }
-        }else if logLine.StatusCode < 400 {
-            if v, ok := statuses[`3xx`]; ok {
-                statuses[`3xx`] = (v + 1)
-            }
-        }else if logLine.StatusCode < 500 {
-            if v, ok := statuses[`4xx`]; ok {
-                statuses[`4xx`] = (v + 1)
-            }
-        }else if logLine.StatusCode < 600 {
-            if v, ok := statuses[`5xx`]; ok {
-                statuses[`5xx`] = (v + 1)
-            }
-        }else{
-            if v, ok := statuses[`???`]; ok {
-                statuses[`???`] = (v + 1)
-            }
-        }
+    for _, class := range StatusClasses {
+        statuses[class] = 0
+    }
+
+    for code, count := range self.Statuses {
+        statuses[StatusFamily(code)] += count
     }
 
     return statuses
 }
 
+// Custom status classes, which take precedence over the standard families (e.g.: to count
+// nginx's 499 responses as "client-closed" rather than as a 4xx).
+//
+var StatusClasses = make(map[uint]string)
+
+// Add a custom status class from a "NAME=CODES" specification, where CODES is a comma-separated
+// list of status codes or ranges (e.g.: "client-closed=499", "cloudflare=520-527").
+//
+func AddStatusClass(spec string) error {
+    parts := strings.SplitN(spec, `=`, 2)
+
+    if len(parts) != 2 || parts[0] == `` || parts[1] == `` {
+        return fmt.Errorf("Status class must be specified as NAME=CODES, got '%s'", spec)
+    }
+
+    codes := make([]uint, 0)
+
+    for _, codeRange := range strings.Split(parts[1], `,`) {
+        bounds := strings.SplitN(strings.TrimSpace(codeRange), `-`, 2)
+
+        if len(bounds) == 1 {
+            bounds = append(bounds, bounds[0])
+        }
+
+        low, err := strconv.ParseUint(bounds[0], 10, 16)
+
+        if err != nil {
+            return fmt.Errorf("Invalid status code '%s' in class '%s'", bounds[0], parts[0])
+        }
+
+        high, err := strconv.ParseUint(bounds[1], 10, 16)
+
+        if err != nil || high < low {
+            return fmt.Errorf("Invalid status code range '%s' in class '%s'", codeRange, parts[0])
+        }
+
+        for code := low; code <= high; code++ {
+            codes = append(codes, uint(code))
+        }
+    }
+
+    for _, code := range codes {
+        StatusClasses[code] = parts[0]
+    }
+
+    return nil
+}
+
+// Return the family (e.g.: "2xx") or custom class that the given status code belongs to.
+//
+func StatusFamily(code uint) string {
+    if class, ok := StatusClasses[code]; ok {
+        return class
+    }
+
+    if code < 200 {
+        return `1xx`
+    }else if code < 300 {
+        return `2xx`
+    }else if code < 400 {
+        return `3xx`
+    }else if code < 500 {
+        return `4xx`
+    }else if code < 600 {
+        return `5xx`
+    }
+
+    return `???`
+}
+
+// A set of statistics, keyed by section name.
+//
+type StatisticSet map[string]*LogStatistic
+
+// Record a log line under the given section, creating the statistic if necessary.
+//
+func (self StatisticSet) Add(section string, logLine *NcsaLog) {
+    stat, ok := self[section]
+
+    if !ok {
+        stat = NewLogStatistic(section)
+        self[section] = stat
+    }
+
+    stat.Add(logLine)
+}
+
+// Accumulate all statistics from another set into this one.
+//
+func (self StatisticSet) Merge(other StatisticSet) {
+    for section, otherStat := range other {
+        stat, ok := self[section]
+
+        if !ok {
+            stat = NewLogStatistic(section)
+            self[section] = stat
+        }
+
+        stat.Merge(otherStat)
+    }
+}
+
 type LogCallback func(NcsaLog, error)
 
 type NcsaLog struct {
-    Host       string
-    Identity   string
-    UserId     string
-    Timestamp  time.Time
-    Method     string
-    Path       string
-    Protocol   string
-    StatusCode uint
-    Size       uint64
-    Rest       string
+    Host         string
+    Identity     string
+    UserId       string
+    Timestamp    time.Time
+    Method       string
+    Path         string
+    Protocol     string
+    StatusCode   uint
+    Size         uint64
+    Rest         string
+    Referer      string
+    UserAgent    string
+    ForwardedFor string
+    Client       string
+    Geo          *GeoLocation
+    Fields       map[string]string
+    Duration     time.Duration
+    HasDuration  bool
+
+    agent        *UserAgentInfo
 }
 
 func ParseStream(input io.Reader, cb LogCallback) error {
@@ -131,7 +442,7 @@ func (self *NcsaLog) Parse(line string) error {
                 case `user`:
                     self.UserId = match[i]
                 case `timestamp`:
-                    if tm, err := time.Parse(NCSA_TIMESTAMP_LAYOUT, match[i]); err == nil {
+                    if tm, err := ParseTimestamp(match[i]); err == nil {
                         self.Timestamp = tm
                     }else{
                         return err
@@ -157,10 +468,22 @@ func (self *NcsaLog) Parse(line string) error {
                 case `rest`:
                     if rest := strings.TrimSpace(match[i]); len(rest) > 0 {
                         self.Rest = rest
+
+                        self.parseRest(rest)
                     }
                 }
             }
 
+            for _, field := range FORWARDED_FOR_FIELDS {
+                if value, ok := self.Fields[field]; ok && value != `` && value != `-` {
+                    self.ForwardedFor = value
+                    break
+                }
+            }
+
+            self.Client = ResolveClientAddress(self.Host, self.ForwardedFor)
+            self.Geo = GeoIP.Lookup(self.Client)
+
         }else{
             return fmt.Errorf("Input did not match parse format: '%s'", line)
         }
@@ -169,4 +492,137 @@ func (self *NcsaLog) Parse(line string) error {
     }
 
     return nil
-}
\ No newline at end of file
+}
+
+// Return the address of the client that made the request.  If the request was received from a
+// trusted proxy, this is the real client address taken from the forwarded-for chain.
+//
+func (self *NcsaLog) ClientAddress() string {
+    if self.Client != `` {
+        return self.Client
+    }
+
+    return self.Host
+}
+
+// Return the normalized cache status of the response (see NormalizeCacheStatus), if the log
+// format includes one.
+//
+func (self *NcsaLog) CacheStatus() (string, bool) {
+    for _, field := range CACHE_STATUS_FIELDS {
+        if value, ok := self.Fields[field]; ok {
+            return NormalizeCacheStatus(value)
+        }
+    }
+
+    return ``, false
+}
+
+// Return the classification of this request's user agent.
+//
+func (self *NcsaLog) Agent() *UserAgentInfo {
+    if self.agent == nil {
+        self.agent = UserAgents.Classify(self.UserAgent)
+    }
+
+    return self.agent
+}
+
+// Parse the fields trailing the end of a log line.  The first two quoted values (if present)
+// are the referer and user agent from the Combined Log Format, and a third is taken to be
+// the X-Forwarded-For header; any "key=value" (or key="quoted value") fields are collected
+// into Fields.  A duration field that cannot be parsed is ignored (leaving the line without a
+// duration) rather than discarding the line.
+//
+func (self *NcsaLog) parseRest(rest string) {
+    positional := 0
+
+    for _, match := range restFieldRx.FindAllStringSubmatch(rest, -1) {
+        key := match[1]
+        value := match[3]
+        quoted := strings.HasSuffix(match[0], `"`) && match[3] == ``
+
+        if quoted {
+            value = strings.Replace(match[2], `\"`, `"`, -1)
+        }
+
+        if key == `` {
+            if quoted {
+                if value != `-` {
+                    switch positional {
+                    case 0:
+                        self.Referer = value
+                    case 1:
+                        self.UserAgent = value
+                    case 2:
+                        self.ForwardedFor = value
+                    }
+                }
+
+                positional += 1
+            }
+
+            continue
+        }
+
+        if self.Fields == nil {
+            self.Fields = make(map[string]string)
+        }
+
+        self.Fields[key] = value
+
+        if unit, ok := DURATION_FIELDS[key]; ok && value != `-` {
+            if v, err := strconv.ParseFloat(value, 64); err == nil {
+                self.Duration = time.Duration(v * float64(unit))
+                self.HasDuration = true
+            }else{
+                log.Debugf("Ignoring invalid value for duration field '%s': %v", key, err)
+            }
+        }
+    }
+}
+
+// Parse a timestamp using each of the NCSA_TIMESTAMP_LAYOUTS in turn, falling back to
+// treating purely numeric values as epoch seconds (or milliseconds, if 13 or more digits
+// long).  The returned time is converted to TimestampLocation.
+//
+func ParseTimestamp(value string) (time.Time, error) {
+    value = strings.TrimSpace(value)
+
+    if match := epochRx.FindStringSubmatch(value); match != nil {
+        whole, err := strconv.ParseInt(match[1], 10, 64)
+
+        if err != nil {
+            return time.Time{}, err
+        }
+
+        var nsec int64
+
+        if frac := match[2]; len(frac) > 0 {
+            if len(frac) > 9 {
+                frac = frac[:9]
+            }
+
+            if v, err := strconv.ParseInt(frac + strings.Repeat(`0`, 9 - len(frac)), 10, 64); err == nil {
+                nsec = v
+            }else{
+                return time.Time{}, err
+            }
+        }
+
+    //  13+ digits puts us past the year 2286 in seconds, so treat it as milliseconds
+        if len(match[1]) >= 13 {
+            return time.Unix(0, (whole * int64(time.Millisecond)) + (nsec / 1000)).In(TimestampLocation), nil
+        }
+
+        return time.Unix(whole, nsec).In(TimestampLocation), nil
+    }
+
+    for _, layout := range NCSA_TIMESTAMP_LAYOUTS {
+        if tm, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+            return tm.In(TimestampLocation), nil
+        }
+    }
+
+    return time.Time{}, fmt.Errorf("Unrecognized timestamp format: '%s'", value)
+}
@@ -0,0 +1,158 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestParseTimestampLayouts(t *testing.T) {
+    TimestampLocation = time.UTC
+    defer func(){ TimestampLocation = time.Local }()
+
+    expected := time.Date(2016, time.March, 16, 2, 58, 38, 0, time.UTC)
+
+    for _, value := range []string{
+        `15/Mar/2016:22:58:38 -0400`,
+        `16/Mar/2016:02:58:38 +0000`,
+        `2016-03-16T02:58:38Z`,
+        `2016-03-15T22:58:38-04:00`,
+        `2016-03-15T22:58:38-0400`,
+        `2016-03-15 22:58:38 -0400`,
+        `1458097118`,
+        `1458097118000`,
+    }{
+        if tm, err := ParseTimestamp(value); err == nil {
+            if !tm.Equal(expected) {
+                t.Errorf("Timestamp '%s' parsed incorrectly: expected %v, got %v", value, expected, tm)
+            }
+
+            if tm.Location() != time.UTC {
+                t.Errorf("Timestamp '%s' was not normalized to UTC, got %v", value, tm.Location())
+            }
+        }else{
+            t.Errorf("Failed to parse '%s': %v", value, err)
+        }
+    }
+}
+
+func TestParseTimestampFractional(t *testing.T) {
+    TimestampLocation = time.UTC
+    defer func(){ TimestampLocation = time.Local }()
+
+    expected := time.Date(2016, time.March, 16, 2, 58, 38, 123000000, time.UTC)
+
+    for _, value := range []string{
+        `15/Mar/2016:22:58:38.123 -0400`,
+        `2016-03-16T02:58:38.123Z`,
+        `1458097118.123`,
+        `1458097118123`,
+    }{
+        if tm, err := ParseTimestamp(value); err == nil {
+            if !tm.Equal(expected) {
+                t.Errorf("Timestamp '%s' parsed incorrectly: expected %v, got %v", value, expected, tm)
+            }
+        }else{
+            t.Errorf("Failed to parse '%s': %v", value, err)
+        }
+    }
+}
+
+func TestParseTimestampInvalid(t *testing.T) {
+    if _, err := ParseTimestamp(`yesterday-ish`); err == nil {
+        t.Errorf("Expected an error parsing an invalid timestamp")
+    }
+}
+
+func TestParseDurationFields(t *testing.T) {
+    logLine := NcsaLog{}
+
+    if err := logLine.Parse(`10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /api/1 HTTP/1.1" 200 512 rt=0.250 upstream="10.0.0.2:80"`); err != nil {
+        t.Fatalf("Failed to parse log line: %v", err)
+    }
+
+    if !logLine.HasDuration || logLine.Duration != 250 * time.Millisecond {
+        t.Errorf("Expected a duration of 250ms, got %v", logLine.Duration)
+    }
+
+    if v := logLine.Fields[`upstream`]; v != `10.0.0.2:80` {
+        t.Errorf("Expected quoted field upstream=10.0.0.2:80, got '%s'", v)
+    }
+
+    logLine = NcsaLog{}
+
+    if err := logLine.Parse(`10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /api/1 HTTP/1.1" 200 512 D=1500`); err != nil {
+        t.Fatalf("Failed to parse log line: %v", err)
+    }
+
+    if !logLine.HasDuration || logLine.Duration != 1500 * time.Microsecond {
+        t.Errorf("Expected a duration of 1.5ms, got %v", logLine.Duration)
+    }
+
+    logLine = NcsaLog{}
+
+    if err := logLine.Parse(`10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /api/1 HTTP/1.1" 200 512 request_time=abc`); err != nil {
+        t.Fatalf("Expected a line with an invalid duration to still be parsed, got: %v", err)
+    }
+
+    if logLine.HasDuration || logLine.StatusCode != 200 {
+        t.Errorf("Expected the line to be kept without a duration, got %v", logLine.Duration)
+    }
+}
+
+func TestStatusClasses(t *testing.T) {
+    defer func(){ StatusClasses = make(map[uint]string) }()
+
+    if err := AddStatusClass(`client-closed=499`); err != nil {
+        t.Fatalf("Failed to add status class: %v", err)
+    }
+
+    if err := AddStatusClass(`cloudflare=520-522,525`); err != nil {
+        t.Fatalf("Failed to add status class: %v", err)
+    }
+
+    stat := NewLogStatistic(`api`)
+
+    for _, code := range []uint{ 200, 404, 499, 499, 503, 521, 525 } {
+        stat.Add(&NcsaLog{ StatusCode: code, Method: `GET`, Protocol: `HTTP/1.1` })
+    }
+
+    families := stat.GroupByStatusFamily()
+
+    for family, count := range map[string]uint64{ `2xx`: 1, `4xx`: 1, `5xx`: 1, `client-closed`: 2, `cloudflare`: 2 } {
+        if families[family] != count {
+            t.Errorf("Expected %s=%d, got %d (%+v)", family, count, families[family], families)
+        }
+    }
+
+    if stat.Statuses[499] != 2 || stat.Methods[`GET`] != 7 || stat.Protocols[`HTTP/1.1`] != 7 {
+        t.Errorf("Unexpected breakdowns: %+v %+v %+v", stat.Statuses, stat.Methods, stat.Protocols)
+    }
+
+    for _, spec := range []string{ `nope`, `x=abc`, `x=500-400` } {
+        if err := AddStatusClass(spec); err == nil {
+            t.Errorf("Expected status class '%s' to be rejected", spec)
+        }
+    }
+}
+
+func TestLogStatisticLazy(t *testing.T) {
+    empty := NewLogStatistic(`*`)
+
+    if empty.Sizes != nil || empty.Hosts != nil || empty.Statuses != nil || empty.AverageSize() != 0 || empty.Hosts.Count() != 0 {
+        t.Errorf("Expected nothing to be allocated before anything is recorded")
+    }
+
+    stat := NewLogStatistic(`api`)
+    stat.Add(&NcsaLog{ Host: `10.0.0.1`, Path: `/api/1`, StatusCode: 200, Size: 100 })
+
+    if stat.Sizes == nil || stat.Hosts.Count() != 1 || stat.Durations != nil || stat.Caches != nil || stat.Countries != nil {
+        t.Errorf("Expected only the breakdowns that saw a value to be allocated, got %+v", stat)
+    }
+
+    stat.Add(&NcsaLog{ Host: `10.0.0.2`, Path: `/api/2`, StatusCode: 200, Duration: time.Second, HasDuration: true })
+    empty.Merge(stat)
+
+    if empty.Count != 2 || empty.Hosts.Count() != 2 || empty.Durations == nil || empty.Durations.Count != 1 || empty.Statuses[200] != 2 {
+        t.Errorf("Expected merging to allocate and fill the breakdowns, got %+v", empty)
+    }
+}
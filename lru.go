@@ -0,0 +1,80 @@
+package main
+
+import (
+    "container/list"
+    "sync"
+)
+
+// A fixed-capacity cache that evicts the least recently used entry once full.  All methods
+// are safe for concurrent use.
+//
+type LRUCache[K comparable, V any] struct {
+    Capacity int
+
+    mx      sync.Mutex
+    order   *list.List
+    entries map[K]*list.Element
+}
+
+type lruEntry[K comparable, V any] struct {
+    key   K
+    value V
+}
+
+func NewLRUCache[K comparable, V any](capacity int) *LRUCache[K, V] {
+    if capacity < 1 {
+        capacity = 1
+    }
+
+    return &LRUCache[K, V]{
+        Capacity: capacity,
+        order:    list.New(),
+        entries:  make(map[K]*list.Element),
+    }
+}
+
+// Return the cached value for the given key (marking it as recently used).
+//
+func (self *LRUCache[K, V]) Get(key K) (V, bool) {
+    self.mx.Lock()
+    defer self.mx.Unlock()
+
+    if element, ok := self.entries[key]; ok {
+        self.order.MoveToFront(element)
+        return element.Value.(*lruEntry[K, V]).value, true
+    }
+
+    var zero V
+    return zero, false
+}
+
+// Store a value, evicting the least recently used entry if the cache is full.
+//
+func (self *LRUCache[K, V]) Put(key K, value V) {
+    self.mx.Lock()
+    defer self.mx.Unlock()
+
+    if element, ok := self.entries[key]; ok {
+        element.Value.(*lruEntry[K, V]).value = value
+        self.order.MoveToFront(element)
+        return
+    }
+
+    self.entries[key] = self.order.PushFront(&lruEntry[K, V]{
+        key:   key,
+        value: value,
+    })
+
+    for self.order.Len() > self.Capacity {
+        oldest := self.order.Back()
+        self.order.Remove(oldest)
+        delete(self.entries, oldest.Value.(*lruEntry[K, V]).key)
+    }
+}
+
+func (self *LRUCache[K, V]) Len() int {
+    self.mx.Lock()
+    defer self.mx.Unlock()
+
+    return self.order.Len()
+}
@@ -0,0 +1,39 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestLRUCacheEviction(t *testing.T) {
+    cache := NewLRUCache[string, int](2)
+
+    cache.Put(`a`, 1)
+    cache.Put(`b`, 2)
+
+//  touching "a" makes "b" the least recently used
+    if v, ok := cache.Get(`a`); !ok || v != 1 {
+        t.Errorf("Expected a=1, got %d", v)
+    }
+
+    cache.Put(`c`, 3)
+
+    if _, ok := cache.Get(`b`); ok {
+        t.Errorf("Expected b to have been evicted")
+    }
+
+    for key, value := range map[string]int{ `a`: 1, `c`: 3 } {
+        if v, ok := cache.Get(key); !ok || v != value {
+            t.Errorf("Expected %s=%d, got %d", key, value, v)
+        }
+    }
+
+    cache.Put(`c`, 4)
+
+    if v, _ := cache.Get(`c`); v != 4 {
+        t.Errorf("Expected c to be updated to 4, got %d", v)
+    }
+
+    if cache.Len() != 2 {
+        t.Errorf("Expected 2 entries, got %d", cache.Len())
+    }
+}
@@ -1,7 +1,7 @@
 package main
 
 import (
-    "fmt"
+    "encoding/json"
     "os"
     "sort"
     "strings"
@@ -14,8 +14,12 @@ import (
 )
 
 const VERSION                        = `0.0.1`
-const DEFAULT_TOP_INTERVAL           = 10
+const DEFAULT_TOP_INTERVAL           = `10s`
+const DEFAULT_RESOLUTION             = `1s`
+const DEFAULT_TREE_DEPTH             = 3
+const DEFAULT_TREE_MIN_SHARE         = 0.05
 const DEFAULT_TOP_COUNT              = -1
+const DEFAULT_TOP_SECTIONS           = 1
 const DEFAULT_MAX_REQUESTS_PER_SEC   = 100
 const DEFAULT_REQUEST_RATE_HISTORY   = 120
 
@@ -24,14 +28,36 @@ var green  = color.New(color.FgGreen).SprintFunc()
 var yellow = color.New(color.FgYellow).SprintFunc()
 var red    = color.New(color.FgRed).SprintFunc()
 
+// Heatmap cell colors, from the quietest to the busiest.
+//
+var heatShades = []func(a ...interface{}) string{
+    color.New(color.BgBlue, color.FgWhite).SprintFunc(),
+    color.New(color.BgCyan, color.FgBlack).SprintFunc(),
+    color.New(color.BgGreen, color.FgBlack).SprintFunc(),
+    color.New(color.BgYellow, color.FgBlack).SprintFunc(),
+    color.New(color.BgRed, color.FgWhite).SprintFunc(),
+}
+
 var mx                    = new(sync.Mutex)
 var alertTriggered        = false
-var totalReqHistory *Ring
+var totalReqHistory *NumericRing[uint64]
 var totalHitsCounter uint64
 
-var sectionStats          = make(map[string]*LogStatistic)
+var sectionWindow *Window
+var slidingWindow         = false
 var streamFinished        = make(chan bool)
 var observations          = 0
+var resolution            time.Duration
+var interval              time.Duration
+var windowSize            time.Duration
+var summaryWindows        []time.Duration
+var grouping *Grouping
+var networkFilter *NetworkFilter
+var sessionTracker *SessionTracker
+var reportOptions  ReportOptions
+var heatmap        *Heatmap
+var timeline       *Timeline
+var trendHistory   *TrendHistory
 
 func main(){
     app                      := cli.NewApp()
@@ -45,24 +71,103 @@ func main(){
             Value:  `info`,
             EnvVar: `LOGLEVEL`,
         },
-        cli.BoolTFlag{
+        cli.IntFlag{
             Name:   `top, t`,
-            Usage:  `Show top site sections`,
+            Usage:  `Show the top N busiest site sections (0 to show all sections)`,
+            Value:  DEFAULT_TOP_SECTIONS,
+        },
+        cli.StringFlag{
+            Name:   `top-by`,
+            Usage:  `Show the top N heavy hitters for a grouping expression (e.g.: "client", "path", "useragent") using a bounded-memory sketch, with approximate counts`,
+        },
+        cli.IntFlag{
+            Name:   `top-capacity`,
+            Usage:  `The number of counters kept by each heavy hitters sketch (--top-by, --top-bandwidth, --referers, --sessions, --transitions and --query-params); larger values are more accurate but use more memory`,
+            Value:  DEFAULT_TOPK_CAPACITY,
+        },
+        cli.StringFlag{
+            Name:   `group-by, g`,
+            Usage:  `Comma-separated list of keys to group sections by: section, path, path:N, route, method, status, family, protocol, host, client, network[:V4/V6], country, region, asn, class, cache, vhost, user, useragent, browser[:version], os[:version], device, bot, field:NAME, regex:EXPR`,
+            Value:  DEFAULT_GROUPING,
+        },
+        cli.StringSliceFlag{
+            Name:   `path-pattern, P`,
+            Usage:  `Replace matches of a regular expression in paths with a placeholder when grouping by route (e.g.: ":sku=[A-Z]{3}-\d+")`,
+        },
+        cli.IntFlag{
+            Name:   `learn-routes`,
+            Usage:  `When grouping by route, collapse any path segment that has seen more than this many distinct values into a placeholder (0 to disable)`,
+        },
+        cli.BoolFlag{
+            Name:   `tree, T`,
+            Usage:  `Show a tree of request paths (e.g.: /api -> /api/v1 -> /api/v1/users), pruned to the top contributors`,
+        },
+        cli.IntFlag{
+            Name:   `tree-depth`,
+            Usage:  `The maximum number of path segments to show in the section tree`,
+            Value:  DEFAULT_TREE_DEPTH,
+        },
+        cli.Float64Flag{
+            Name:   `tree-min-share`,
+            Usage:  `The minimum share (0-1) of total hits a path must have to be shown in the section tree`,
+            Value:  DEFAULT_TREE_MIN_SHARE,
+        },
+        cli.StringSliceFlag{
+            Name:   `include-network`,
+            Usage:  `Only include requests from clients in these networks (CIDR notation, e.g.: "203.0.113.0/24")`,
+        },
+        cli.StringSliceFlag{
+            Name:   `exclude-network`,
+            Usage:  `Exclude requests from clients in these networks (CIDR notation), such as internal monitors`,
+        },
+        cli.StringSliceFlag{
+            Name:   `trusted-proxy`,
+            Usage:  `Networks (CIDR notation) of proxies and load balancers whose X-Forwarded-For chain should be used to find the real client address`,
+        },
+        cli.StringFlag{
+            Name:   `forwarded-for-field`,
+            Usage:  `The name of a custom log field holding the X-Forwarded-For chain (in addition to common names like "http_x_forwarded_for")`,
+        },
+        cli.StringSliceFlag{
+            Name:   `geoip-db`,
+            Usage:  `Enrich requests with the country, region and ASN of the client from these MaxMind DB (.mmdb) files (e.g.: GeoLite2-City.mmdb, GeoLite2-ASN.mmdb)`,
+        },
+        cli.IntFlag{
+            Name:   `geoip-cache`,
+            Usage:  `The number of client addresses whose GeoIP lookups are cached`,
+            Value:  DEFAULT_GEOIP_CACHE_SIZE,
+        },
+        cli.StringSliceFlag{
+            Name:   `ua-rules`,
+            Usage:  `Load additional user agent classification rules from these files (see useragents.rules for the format); these take precedence over the built-in rules`,
         },
         cli.StringSliceFlag{
             Name:   `with-section, S`,
-            Usage:  `When displaying multiple sections (--top=false), choose which ones to show`,
+            Usage:  `When displaying multiple sections (--top=0), choose which ones to show`,
         },
         cli.IntFlag{
             Name:   `count, c`,
             Usage:  `The maximum number of iterations to output in "top" mode`,
             Value:  DEFAULT_TOP_COUNT,
         },
-        cli.IntFlag{
+        cli.StringFlag{
             Name:   `interval, i`,
-            Usage:  `Interval (in seconds) that the top output window will summarize results for`,
+            Usage:  `Interval (e.g.: "10s", "5m") that the top output window will summarize results for; summaries are aligned to the wall clock`,
             Value:  DEFAULT_TOP_INTERVAL,
         },
+        cli.StringFlag{
+            Name:   `resolution, r`,
+            Usage:  `How often (e.g.: "500ms", "1s") hit counts are sampled; the interval must be a multiple of this`,
+            Value:  DEFAULT_RESOLUTION,
+        },
+        cli.StringFlag{
+            Name:   `window, w`,
+            Usage:  `Summarize a sliding window of this duration (e.g.: "5m") every interval, instead of only the hits seen since the previous summary`,
+        },
+        cli.StringFlag{
+            Name:   `windows, W`,
+            Usage:  `Comma-separated list of windows (e.g.: "1m,5m,15m") to summarize side by side, along with smoothed hit rates (like load averages)`,
+        },
         cli.BoolTFlag{
             Name:   `request-hits-alerts, A`,
             Usage:  `Show alerts when the total hits of requests per history window exceeds a configured threshold`,
@@ -74,9 +179,138 @@ func main(){
         },
         cli.IntFlag{
             Name:   `request-hits-history, H`,
-            Usage:  `How many observations to store (at per-second resoltion) when averaging the total hit count for alerting`,
+            Usage:  `How many observations to store (one per --resolution) when averaging the total hit count for alerting`,
             Value:  DEFAULT_REQUEST_RATE_HISTORY,
         },
+        cli.StringFlag{
+            Name:   `timezone, z`,
+            Usage:  `Normalize all displayed times to this timezone (e.g.: "UTC", "America/New_York")`,
+            Value:  `Local`,
+        },
+        cli.StringFlag{
+            Name:   `statuses, s`,
+            Usage:  `Break responses down by status "families" (e.g.: 4xx), individual status "codes" (e.g.: 404), or "both"`,
+            Value:  STATUS_DISPLAY_FAMILIES,
+        },
+        cli.StringSliceFlag{
+            Name:   `status-class`,
+            Usage:  `Count the given status codes as their own class instead of as part of their family (e.g.: "client-closed=499", "cloudflare=520-527")`,
+        },
+        cli.BoolFlag{
+            Name:   `show-methods, m`,
+            Usage:  `Include a breakdown of request methods and protocol versions in section summaries`,
+        },
+        cli.StringFlag{
+            Name:   `output, o`,
+            Usage:  `Output format for section summaries: "text", "json" (one object per interval) or "csv"`,
+            Value:  OUTPUT_TEXT,
+        },
+        cli.IntFlag{
+            Name:   `top-bandwidth`,
+            Usage:  `Show the N clients that consumed the most bandwidth in each interval`,
+        },
+        cli.IntFlag{
+            Name:   `referers`,
+            Usage:  `Show the N top referring domains (internal and external) and the N inbound links (referer → path) that most often led to a 404 in each interval`,
+        },
+        cli.StringSliceFlag{
+            Name:   `internal-domain`,
+            Usage:  `Domains (including their subdomains) whose referers are internal links within this site`,
+        },
+        cli.IntFlag{
+            Name:   `sessions`,
+            Usage:  `Reconstruct visitor sessions (by client address and user agent) and show session metrics along with the N most common entry and exit pages in each interval`,
+        },
+        cli.StringFlag{
+            Name:   `session-timeout`,
+            Usage:  `How long a client must be inactive before its session ends`,
+            Value:  DEFAULT_SESSION_TIMEOUT,
+        },
+        cli.IntFlag{
+            Name:   `max-sessions`,
+            Usage:  `The most sessions kept open at once; beyond this, the least recently active sessions are ended early`,
+            Value:  DEFAULT_MAX_SESSIONS,
+        },
+        cli.StringFlag{
+            Name:   `session-log`,
+            Usage:  `Write a summary of each session as it ends to this file (as one JSON object per line)`,
+        },
+        cli.StringSliceFlag{
+            Name:   `funnel-step`,
+            Usage:  `Report how many sessions reached each of these steps in order (repeat for each step); each step is a regular expression matched against the start of the path (e.g.: --funnel-step /products --funnel-step /cart --funnel-step /checkout)`,
+        },
+        cli.StringFlag{
+            Name:   `transitions`,
+            Usage:  `Write the graph of page-to-page transitions within sessions to this file in Graphviz DOT format at the end of each interval`,
+        },
+        cli.IntFlag{
+            Name:   `transition-edges`,
+            Usage:  `The number of most common transitions to include in the transition graph`,
+            Value:  DEFAULT_TRANSITION_EDGES,
+        },
+        cli.IntFlag{
+            Name:   `query-params`,
+            Usage:  `Show the N most frequently used query string parameters in each interval, with the number of distinct values of each`,
+        },
+        cli.StringSliceFlag{
+            Name:   `query-values`,
+            Usage:  `Also show the top values of these query string parameters (e.g.: "utm_source,utm_campaign")`,
+        },
+        cli.BoolFlag{
+            Name:   `redact`,
+            Usage:  `Redact the values of common sensitive query string parameters (e.g.: "token", "password", "api_key") from any output`,
+        },
+        cli.StringSliceFlag{
+            Name:   `redact-param`,
+            Usage:  `Redact the values of these query string parameters from any output (in addition to those of --redact, if given)`,
+        },
+        cli.StringFlag{
+            Name:   `heatmap`,
+            Usage:  `Once the input ends, show a heatmap of requests by hour of the day and day of the week: either of all requests ("total"), or per section ("section") or status class ("status")`,
+        },
+        cli.StringFlag{
+            Name:   `heatmap-timezone`,
+            Usage:  `Place requests in the heatmap by the hour and day in this timezone (defaults to --timezone)`,
+        },
+        cli.StringFlag{
+            Name:   `heatmap-file`,
+            Usage:  `Write the heatmap to this file (as JSON if its name ends in ".json" and as CSV otherwise) rather than including it in JSON or CSV output`,
+        },
+        cli.StringFlag{
+            Name:   `timeline`,
+            Usage:  `Once the input ends, show a histogram of requests over time per section and status family, in buckets of this width (e.g.: "1m"), or "auto" to fit the time span of the input`,
+        },
+        cli.IntFlag{
+            Name:   `timeline-buckets`,
+            Usage:  `With --timeline=auto, the most buckets to divide the time span of the input into`,
+            Value:  DEFAULT_TIMELINE_BUCKETS,
+        },
+        cli.StringFlag{
+            Name:   `timeline-file`,
+            Usage:  `Also write the timeline to this file as CSV`,
+        },
+        cli.BoolFlag{
+            Name:   `trends`,
+            Usage:  `Show how each section's requests and error ratio changed since the previous interval, and since the same interval yesterday and last week (once that much history has been seen; only meaningful when following live logs, since a replayed file is summarized in far fewer intervals than it spans)`,
+        },
+        cli.Float64Flag{
+            Name:   `trend-threshold`,
+            Usage:  `Highlight trends where requests changed by at least this fraction (e.g.: 0.5 for 50%)`,
+            Value:  DEFAULT_TREND_THRESHOLD,
+        },
+        cli.Float64Flag{
+            Name:   `trend-error-threshold`,
+            Usage:  `Show (and highlight) changes in error ratio of at least this much (e.g.: 0.01 for 1 percentage point)`,
+            Value:  DEFAULT_TREND_ERROR_THRESHOLD,
+        },
+        cli.BoolFlag{
+            Name:   `cache`,
+            Usage:  `Include a breakdown of cache statuses (hit, miss, expired, ...) and the cache hit ratio of each section, read from the upstream_cache_status or x-cache field`,
+        },
+        cli.StringSliceFlag{
+            Name:   `content-class`,
+            Usage:  `Classify requests by extension or path prefix for the "class" grouping (e.g.: "image=.heic,.jxl" or "api=/graphql"), in addition to the built-in classes`,
+        },
         cli.BoolFlag{
             Name:   `no-color`,
             Usage:  `Disable colors in terminal output`,
@@ -113,32 +347,331 @@ func main(){
             })
         }
 
+        if tz := c.String(`timezone`); tz != `` {
+            if loc, err := time.LoadLocation(tz); err == nil {
+                TimestampLocation = loc
+            }else{
+                log.Fatalf("Invalid timezone '%s': %v", tz, err)
+            }
+        }
+
+        if v, err := ParseDuration(c.String(`resolution`)); err == nil && v > 0 {
+            resolution = v
+        }else{
+            log.Fatalf("Invalid resolution '%s'", c.String(`resolution`))
+        }
+
+        if v, err := ParseDuration(c.String(`interval`)); err == nil && v >= resolution && v % resolution == 0 {
+            interval = v
+        }else{
+            log.Fatalf("Invalid interval '%s': must be a multiple of the resolution (%v)", c.String(`interval`), resolution)
+        }
+
+        windowSize = interval
+
+        if w := c.String(`window`); w != `` {
+            if v, err := ParseDuration(w); err == nil && v >= resolution && v % resolution == 0 {
+                windowSize = v
+                slidingWindow = true
+            }else{
+                log.Fatalf("Invalid window '%s': must be a multiple of the resolution (%v)", w, resolution)
+            }
+        }
+
+        if w := c.String(`windows`); w != `` {
+            for _, value := range strings.Split(w, `,`) {
+                if v, err := ParseDuration(value); err == nil && v >= resolution && v % resolution == 0 {
+                    summaryWindows = append(summaryWindows, v)
+
+                    if v > windowSize {
+                        windowSize = v
+                    }
+                }else{
+                    log.Fatalf("Invalid window '%s': must be a multiple of the resolution (%v)", value, resolution)
+                }
+            }
+
+            sort.Sort(durations(summaryWindows))
+        }
+
+        switch display := c.String(`statuses`); display {
+        case STATUS_DISPLAY_FAMILIES, STATUS_DISPLAY_CODES, STATUS_DISPLAY_BOTH:
+            StatusDisplay = display
+        default:
+            log.Fatalf("Invalid status display '%s': must be one of families, codes or both", display)
+        }
+
+        for _, spec := range c.StringSlice(`status-class`) {
+            if err := AddStatusClass(spec); err != nil {
+                log.Fatalf("%v", err)
+            }
+        }
+
+        ShowMethods = c.Bool(`show-methods`)
+        ShowCache = c.Bool(`cache`)
+        ShowTrends = c.Bool(`trends`)
+        TrendThreshold = c.Float64(`trend-threshold`)
+        TrendErrorThreshold = c.Float64(`trend-error-threshold`)
+
+        if ShowTrends {
+            trendHistory = NewTrendHistory(interval)
+        }
+
+        for _, spec := range c.StringSlice(`content-class`) {
+            if err := AddContentRule(spec); err != nil {
+                log.Fatalf("%v", err)
+            }
+        }
+
+        if by := c.String(`heatmap`); by != `` {
+            location := TimestampLocation
+
+            if tz := c.String(`heatmap-timezone`); tz != `` {
+                if loc, err := time.LoadLocation(tz); err == nil {
+                    location = loc
+                }else{
+                    log.Fatalf("Invalid heatmap timezone '%s': %v", tz, err)
+                }
+            }
+
+            if h, err := NewHeatmap(by, location); err == nil {
+                heatmap = h
+            }else{
+                log.Fatalf("%v", err)
+            }
+        }
+
+        if spec := c.String(`timeline`); spec != `` {
+            if t, err := ParseTimeline(spec, c.Int(`timeline-buckets`)); err == nil {
+                timeline = t
+            }else{
+                log.Fatalf("%v", err)
+            }
+        }
+
+        for _, spec := range c.StringSlice(`path-pattern`) {
+            if err := PathTemplates.AddPattern(spec); err != nil {
+                log.Fatalf("%v", err)
+            }
+        }
+
+        if threshold := c.Int(`learn-routes`); threshold > 0 {
+            PathTemplates.LearnRoutes(threshold)
+        }
+
+        if networks, err := ParseNetworks(c.StringSlice(`trusted-proxy`)); err == nil {
+            TrustedProxies = networks
+        }else{
+            log.Fatalf("%v", err)
+        }
+
+        if field := c.String(`forwarded-for-field`); field != `` {
+            FORWARDED_FOR_FIELDS = append([]string{ field }, FORWARDED_FOR_FIELDS...)
+        }
+
+        if paths := c.StringSlice(`geoip-db`); len(paths) > 0 {
+            if resolver, err := NewGeoResolver(paths, c.Int(`geoip-cache`)); err == nil {
+                GeoIP = resolver
+            }else{
+                log.Fatalf("%v", err)
+            }
+        }
+
+        for _, path := range c.StringSlice(`ua-rules`) {
+            if err := UserAgents.LoadRulesFile(path); err != nil {
+                log.Fatalf("Invalid user agent rules: %v", err)
+            }
+        }
+
+        networkFilter = &NetworkFilter{}
+
+        if networks, err := ParseNetworks(c.StringSlice(`include-network`)); err == nil {
+            networkFilter.Allow = networks
+        }else{
+            log.Fatalf("%v", err)
+        }
+
+        if networks, err := ParseNetworks(c.StringSlice(`exclude-network`)); err == nil {
+            networkFilter.Deny = networks
+        }else{
+            log.Fatalf("%v", err)
+        }
+
+        if g, err := ParseGrouping(c.String(`group-by`)); err == nil {
+            grouping = g
+        }else{
+            log.Fatalf("Invalid grouping: %v", err)
+        }
+
+        bucketOptions := BucketOptions{}
+
+        if capacity := c.Int(`top-capacity`); capacity > 0 {
+            bucketOptions.TopKCapacity = capacity
+        }else{
+            log.Fatalf("Invalid heavy hitters capacity %d: must be at least 1", capacity)
+        }
+
+        if c.Bool(`tree`) {
+            if len(summaryWindows) > 0 {
+                log.Fatalf("The section tree cannot be combined with multiple windows")
+            }
+
+            if depth := c.Int(`tree-depth`); depth > 0 {
+                bucketOptions.TreeDepth = depth
+            }else{
+                log.Fatalf("Invalid tree depth %d", depth)
+            }
+        }
+
+        if expression := c.String(`top-by`); expression != `` {
+            if len(summaryWindows) > 0 || c.Bool(`tree`) {
+                log.Fatalf("Heavy hitters cannot be combined with multiple windows or the section tree")
+            }
+
+            if g, err := ParseGrouping(expression); err == nil {
+                bucketOptions.TopKGrouping = g
+            }else{
+                log.Fatalf("Invalid heavy hitters grouping: %v", err)
+            }
+
+            if capacity := bucketOptions.TopKCapacity; capacity < c.Int(`top`) {
+                log.Fatalf("Invalid heavy hitters capacity %d: must be at least --top", capacity)
+            }
+        }
+
+        switch c.String(`output`) {
+        case OUTPUT_TEXT:
+        case OUTPUT_JSON, OUTPUT_CSV:
+            if len(summaryWindows) > 0 || c.Bool(`tree`) || c.String(`top-by`) != `` {
+                log.Fatalf("The '%s' output format is only supported when summarizing sections", c.String(`output`))
+            }
+        default:
+            log.Fatalf("Unknown output format '%s'", c.String(`output`))
+        }
+
+        if n := c.Int(`top-bandwidth`); n > 0 {
+            bucketOptions.TopBandwidth = n
+        }
+
+        if n := c.Int(`referers`); n > 0 {
+            bucketOptions.Referers = n
+        }
+
+        InternalDomains = c.StringSlice(`internal-domain`)
+
+        reportOptions = ReportOptions{
+            TopBandwidth: c.Int(`top-bandwidth`),
+            TopReferers:  c.Int(`referers`),
+            TopPages:     c.Int(`sessions`),
+            TopParams:    c.Int(`query-params`),
+        }
+
+        if steps := c.StringSlice(`funnel-step`); len(steps) > 0 {
+            if funnel, err := ParseFunnel(steps); err == nil {
+                reportOptions.Funnel = funnel
+            }else{
+                log.Fatalf("%v", err)
+            }
+        }
+
+        if c.Bool(`redact`) {
+            AddRedactedParams(SENSITIVE_PARAMS...)
+        }
+
+        for _, names := range c.StringSlice(`redact-param`) {
+            AddRedactedParams(strings.Split(names, `,`)...)
+        }
+
+        for _, names := range c.StringSlice(`query-values`) {
+            for _, name := range strings.Split(names, `,`) {
+                if name = strings.TrimSpace(name); name != `` {
+                    bucketOptions.QueryValues = append(bucketOptions.QueryValues, name)
+                }
+            }
+        }
+
+        if len(bucketOptions.QueryValues) > 0 && reportOptions.TopParams <= 0 {
+            reportOptions.TopParams = DEFAULT_QUERY_PARAMS
+        }
+
+        bucketOptions.QueryParams = (reportOptions.TopParams > 0)
+        bucketOptions.Sessions = (c.Int(`sessions`) > 0 || reportOptions.Funnel != nil)
+        bucketOptions.Transitions = (c.String(`transitions`) != ``)
+
+        sectionWindow = NewWindowWithOptions(resolution, windowSize, bucketOptions)
+        sectionWindow.TrackRates(summaryWindows)
+
+        if bucketOptions.Sessions || bucketOptions.Transitions {
+            var sessionLog *json.Encoder
+
+            timeout, err := ParseDuration(c.String(`session-timeout`))
+
+            if err != nil || timeout <= 0 {
+                log.Fatalf("Invalid session timeout '%s'", c.String(`session-timeout`))
+            }
+
+            if c.Int(`max-sessions`) < 1 {
+                log.Fatalf("Invalid maximum number of sessions %d", c.Int(`max-sessions`))
+            }
+
+            if filename := c.String(`session-log`); filename != `` {
+                if file, err := os.Create(filename); err == nil {
+                    defer file.Close()
+                    sessionLog = json.NewEncoder(file)
+                }else{
+                    log.Fatalf("Failed to open session log: %v", err)
+                }
+            }
+
+            sessionTracker = NewSessionTracker(timeout, c.Int(`max-sessions`), func(session *Session){
+                sectionWindow.ObserveSession(session)
+
+                if sessionLog != nil {
+                    if err := sessionLog.Encode(session); err != nil {
+                        log.Errorf("Failed to write session log: %v", err)
+                    }
+                }
+            })
+
+            sessionTracker.Funnel = reportOptions.Funnel
+            sessionTracker.OnTransition = sectionWindow.ObserveTransition
+        }
+
         log.Debugf("Starting %s %s", c.App.Name, c.App.Version)
 
         go func(){
             err := ParseStream(os.Stdin, func(logLine NcsaLog, err error){
                 if err == nil {
+                    if !networkFilter.Permits(logLine.ClientAddress()) {
+                        return
+                    }
+
                     mx.Lock()
                     totalHitsCounter += 1
 
-                    parts := strings.Split(logLine.Path, `/`)
+                //  each request is counted once towards route learning (if enabled); everything
+                //  else only looks up its route
+                    if PathTemplates.Learning() {
+                        PathTemplates.Observe(logLine.Path)
+                    }
 
                 //  this is where statistics are appended for each log line received
-                    if len(parts) > 1 {
-                        sectionName := strings.Split(parts[1], `?`)[0]
+                    sectionWindow.Observe(&logLine)
 
-                        stat, ok := sectionStats[sectionName]
+                    if sessionTracker != nil {
+                        sessionTracker.Add(&logLine)
+                    }
 
+                    if sectionName, ok := grouping.Key(&logLine); ok {
+                        sectionWindow.Add(sectionName, &logLine)
 
-                        if !ok {
-                            stat = NewLogStatistic(sectionName)
-                            sectionStats[sectionName] = stat
+                        if heatmap != nil {
+                            heatmap.Add(sectionName, &logLine)
                         }
 
-                        stat.Count += 1
-                        stat.Sizes = append(stat.Sizes, logLine.Size)
-                        stat.Logs  = append(stat.Logs, &logLine)
-
+                        if timeline != nil {
+                            timeline.Add(sectionName, &logLine)
+                        }
                     }
 
                     mx.Unlock()
@@ -156,22 +689,63 @@ func main(){
 
     //  allocate ring buffer if we're monitoring average hit count
         if c.Bool(`request-hits-alerts`) {
-            log.Debugf("Monitoring total hits (average over %d seconds should not exceed %d)", c.Int(`request-hits-history`), c.Int(`requests-max-hits`))
-            totalReqHistory = NewRing(c.Int(`request-hits-history`))
+            if history := c.Int(`request-hits-history`); history < 1 {
+                log.Fatalf("Invalid request hits history %d: must be at least 1", history)
+            }
+
+            log.Debugf("Monitoring total hits (average over %v should not exceed %d)", time.Duration(c.Int(`request-hits-history`)) * resolution, c.Int(`requests-max-hits`))
+            totalReqHistory = NewNumericRing[uint64](c.Int(`request-hits-history`))
         }
 
-        fmt.Printf("section \tcount \tresponses \n")
+        if len(summaryWindows) > 0 {
+            PrintMultiWindowHeader(grouping.Expression, summaryWindows)
+        }else if c.Bool(`tree`) {
+            PrintSectionTreeHeader()
+        }else if c.String(`top-by`) != `` {
+            PrintHeavyHittersHeader(c.String(`top-by`))
+        }else if c.String(`output`) == OUTPUT_CSV {
+            WriteSummaryCSVHeader(os.Stdout)
+        }else if c.String(`output`) == OUTPUT_TEXT {
+            PrintSectionHeader(grouping.Expression)
+        }
 
-        for {
-        //  update and reset hits/sec counter
-            UpdateHitCounter()
+        scheduler := NewScheduler(resolution)
+        defer scheduler.Stop()
 
+        for {
             select {
             case <-streamFinished:
-                ProcessLogs(c, true)
+            //  any sessions still open at the end of the input are over
+                if sessionTracker != nil {
+                    mx.Lock()
+                    sessionTracker.Flush()
+                    mx.Unlock()
+                }
+
+                UpdateHitCounter()
+                ProcessLogs(c, time.Now(), true)
+
+                if heatmap != nil {
+                    mx.Lock()
+                    WriteHeatmap(c, heatmap)
+                    mx.Unlock()
+                }
+
+                if timeline != nil {
+                    mx.Lock()
+                    WriteTimeline(c, timeline)
+                    mx.Unlock()
+                }
+
+                if routes := PathTemplates.LearnedRoutes(); len(routes) > 0 {
+                    log.Debugf("Learned routes: %s", strings.Join(routes, `, `))
+                }
+
                 return
-            case <-time.After(time.Second):
-                ProcessLogs(c, false)
+            case tick := <-scheduler.C:
+            //  update and reset hits/resolution counter
+                UpdateHitCounter()
+                ProcessLogs(c, tick, false)
             }
         }
     }
@@ -179,96 +753,118 @@ func main(){
     app.Run(os.Args)
 }
 
-func ProcessLogs(c *cli.Context, forced bool) {
+func ProcessLogs(c *cli.Context, tick time.Time, forced bool) {
     observations += 1
 
 
-    sections := make([]*LogStatistic, 0)
+//  only print rollups on every <interval> boundary
+    if (IsIntervalBoundary(tick, interval) || forced) && len(summaryWindows) > 0 {
+        spans := make([]*Bucket, len(summaryWindows))
 
-    var topSection *LogStatistic
+        mx.Lock()
 
-//  because we're working with a map that is accessed/modified across goroutines,
-//  we grab a mutex to safely iterate over it without risk of it changing midway through
-    mx.Lock()
+        for i, w := range summaryWindows {
+            spans[i] = sectionWindow.Span(w)
+        }
 
-    for _, stat := range sectionStats {
-    //  if we're in "top" mode, accumulate logs on an interval and summarize them
-        if c.Bool(`top`) {
-            if topSection == nil {
-                topSection = stat
-            }
+        rates := sectionWindow.Rates()
 
-            if stat.Count > topSection.Count {
-                topSection = stat
-            }
+        mx.Unlock()
+
+        log.Infof("Time: %s", tick.In(TimestampLocation).Format(time.RFC3339Nano))
+
+    //  the largest window contains every section seen in the smaller ones
+        PrintMultiWindowSummary(SelectSections(c, spans[len(spans) - 1].Sections), spans, rates)
+        LogTotals(spans[len(spans) - 1])
+
+    }else if IsIntervalBoundary(tick, interval) || forced {
+        var summary *Bucket
 
-    //  ...otherwise, we're processing all sections that we have stats for
+    //  because the window is modified across goroutines, we grab a mutex to safely merge
+    //  its buckets without risk of them changing midway through
+        mx.Lock()
+
+        if slidingWindow {
+            summary = sectionWindow.Sliding()
         }else{
-            if onlySections := c.StringSlice(`with-section`); len(onlySections) > 0 {
-                for _, name := range onlySections {
-                    if stat.Key == name {
-                        sections = append(sections, stat)
-                        break
-                    }
-                }
+            summary = sectionWindow.Tumbling()
+        }
 
-            }else{
-                sections = append(sections, stat)
-            }
+        mx.Unlock()
+
+        if slidingWindow {
+            log.Infof("Time: %s (last %v)", tick.In(TimestampLocation).Format(time.RFC3339Nano), windowSize)
+        }else{
+            log.Infof("Time: %s", tick.In(TimestampLocation).Format(time.RFC3339Nano))
         }
-    }
 
-//  release mutex
-    mx.Unlock()
+        if summary.Tree != nil {
+            PrintSectionTree(summary.Tree, c.Int(`tree-depth`), c.Float64(`tree-min-share`))
+        }else if summary.TopK != nil {
+            PrintHeavyHitters(summary.TopK, c.Int(`top`))
+        }else{
+            sections := SelectSections(c, summary.Sections)
+            record := NewSummaryRecord(tick, summary, sections, reportOptions)
 
-    if topSection != nil {
-        sections = []*LogStatistic{ topSection }
-    }
+            if record.Sessions != nil {
+                mx.Lock()
+                record.Sessions.Active = sessionTracker.Len()
+                mx.Unlock()
+            }
 
-//  only print rollups and reset counters every <interval> seconds
-    if observations % c.Int(`interval`) == 0 || forced {
-        log.Infof("Time: %s", time.Now().Format(time.RFC3339))
+            if trendHistory != nil {
+                trendHistory.Apply(summary.Totals.Last, &record, summary.Sections)
+            }
 
-        for _, section := range sections {
-            if section != nil {
-                fmt.Printf("%s \t%d \t", section.Key, section.Count)
+            switch c.String(`output`) {
+            case OUTPUT_JSON:
+                WriteSummaryJSON(os.Stdout, record)
+            case OUTPUT_CSV:
+                WriteSummaryCSV(os.Stdout, record)
+            default:
+                trends := make([]map[string]TrendRecord, len(record.Sections))
 
-                families := make([]string, 0)
+                for i, section := range record.Sections {
+                    trends[i] = section.Trends
+                }
 
-                for status, count := range section.GroupByStatusFamily() {
-                    if count > 0 {
-                        families = append(families, fmt.Sprintf("%s=%d", status, count))
-                    }
+                PrintSectionSummary(sections, summary, trends)
+
+                if len(record.TopBandwidth) > 0 {
+                    PrintTopBandwidth(record.TopBandwidth)
                 }
 
-                sort.Strings(families)
-
-                for _, fam := range families {
-                    switch fam[0] {
-                    case '1':
-                    case '2':
-                        fam = green(fam)
-                    case '4':
-                        fam = yellow(fam)
-                    case '5':
-                        fam = red(fam)
-                    default:
-                        fam = blue(fam)
-                    }
+                if len(record.Referers) > 0 || len(record.BrokenLinks) > 0 {
+                    PrintReferers(record.Referers, record.BrokenLinks)
+                }
 
-                    fmt.Printf("%s ", fam)
+                if record.Sessions != nil {
+                    PrintSessions(record.Sessions)
                 }
 
-                fmt.Printf("\n")
-            }else{
-                fmt.Printf("%s \t%d\n", `-`, 0)
+                if len(record.Funnel) > 0 {
+                    PrintFunnel(record.Funnel)
+                }
+
+                if len(record.QueryParams) > 0 {
+                    PrintQueryParams(record.QueryParams)
+                }
             }
         }
 
-        mx.Lock()
-        sectionStats = make(map[string]*LogStatistic)
-        mx.Unlock()
+        if summary.Transitions != nil {
+            if file, err := os.Create(c.String(`transitions`)); err == nil {
+                if err := summary.Transitions.WriteDOT(file, c.Int(`transition-edges`)); err != nil {
+                    log.Errorf("Failed to write transition graph: %v", err)
+                }
+
+                file.Close()
+            }else{
+                log.Errorf("Failed to write transition graph: %v", err)
+            }
+        }
 
+        LogTotals(summary)
     }
 
     if c.Bool(`request-hits-alerts`) {
@@ -277,26 +873,13 @@ func ProcessLogs(c *cli.Context, forced bool) {
     //  if at least <request-hits-history> writes have occurred since the last clear, we can check the history
     //  for whether we should alert or not
         if totalReqHistory.WriteCount() >= totalReqHistory.Length() {
-            var avgHits uint64
-
-            // log.Debugf("  Checking: %+v", totalReqHistory.Data)
-
-            for _, v := range totalReqHistory.Data {
-                switch v.(type) {
-                case uint64:
-                    avgHits += v.(uint64)
-                default:
-                    log.Errorf("Unhandled history value type %T", v)
-                }
-            }
-
-            avgHits = avgHits / uint64(totalReqHistory.Length())
+            avgHits := uint64(totalReqHistory.Mean())
 
 
         //  if the alert is in a triggered state, then we're checking to see if it has cleared
             if alertTriggered {
                 if avgHits < uint64(c.Int(`requests-max-hits`)) {
-                    log.Infof("Traffic has returned to normal levels - hits = %d at %s", avgHits, time.Now())
+                    log.Infof("Traffic has returned to normal levels - hits = %d at %s", avgHits, time.Now().In(TimestampLocation))
                     alertTriggered = false
 
                 //  clear the history to force it to re-accumulate in order to trigger the alert again
@@ -306,7 +889,7 @@ func ProcessLogs(c *cli.Context, forced bool) {
         //  ...otherwise, we check to see if we should be firing the alert
             }else{
                 if avgHits > uint64(c.Int(`requests-max-hits`)) {
-                    log.Errorf("High traffic generated an alert - hits = %d, triggered at %s", avgHits, time.Now())
+                    log.Errorf("High traffic generated an alert - hits = %d, triggered at %s", avgHits, time.Now().In(TimestampLocation))
                     alertTriggered = true
 
                 //  clear the history to force it to re-accumulate in order to clear the alert
@@ -329,13 +912,78 @@ func ProcessLogs(c *cli.Context, forced bool) {
 }
 
 
+// Print the heatmap in the selected output format, and write it to the --heatmap-file, if
+// given (in which case the JSON and CSV output formats leave it out of standard output).
+//
+func WriteHeatmap(c *cli.Context, heatmap *Heatmap) {
+    switch c.String(`output`) {
+    case OUTPUT_TEXT:
+        PrintHeatmap(heatmap)
+    case OUTPUT_JSON:
+        if c.String(`heatmap-file`) == `` {
+            WriteHeatmapJSON(os.Stdout, heatmap)
+        }
+    case OUTPUT_CSV:
+        if c.String(`heatmap-file`) == `` {
+            WriteHeatmapCSV(os.Stdout, heatmap)
+        }
+    }
+
+    if filename := c.String(`heatmap-file`); filename != `` {
+        if file, err := os.Create(filename); err == nil {
+            if strings.HasSuffix(filename, `.json`) {
+                err = WriteHeatmapJSON(file, heatmap)
+            }else{
+                err = WriteHeatmapCSV(file, heatmap)
+            }
+
+            if err != nil {
+                log.Errorf("Failed to write heatmap: %v", err)
+            }
+
+            file.Close()
+        }else{
+            log.Errorf("Failed to write heatmap: %v", err)
+        }
+    }
+}
+
+// Print the timeline (in text output mode) and write it to the --timeline-file, if given.
+//
+func WriteTimeline(c *cli.Context, timeline *Timeline) {
+    if c.String(`output`) == OUTPUT_TEXT {
+        PrintTimeline(timeline)
+    }
+
+    if filename := c.String(`timeline-file`); filename != `` {
+        if file, err := os.Create(filename); err == nil {
+            if err := WriteTimelineCSV(file, timeline); err != nil {
+                log.Errorf("Failed to write timeline: %v", err)
+            }
+
+            file.Close()
+        }else{
+            log.Errorf("Failed to write timeline: %v", err)
+        }
+    }
+}
+
 // This function will push the current hit count into the ring buffer
-// and then reset the count (synchronously)
+// and then reset the count, and close out the current statistics bucket (synchronously)
 //
 func UpdateHitCounter() {
     mx.Lock()
 
-    totalReqHistory.Push(totalHitsCounter)
+    if sessionTracker != nil {
+        sessionTracker.Tick(time.Now())
+    }
+
+    sectionWindow.Advance()
+
+    if totalReqHistory != nil {
+        totalReqHistory.Push(totalHitsCounter)
+    }
+
     totalHitsCounter = 0
 
     mx.Unlock()
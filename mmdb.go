@@ -0,0 +1,391 @@
+package main
+
+import (
+    "bytes"
+    "encoding/binary"
+    "fmt"
+    "math"
+    "net"
+    "os"
+)
+
+// Marks the start of the metadata section at the end of a MaxMind DB file.
+//
+var MMDB_METADATA_MARKER = []byte("\xAB\xCD\xEFMaxMind.com")
+
+const MMDB_DATA_SEPARATOR_SIZE = 16
+
+// How deeply maps, arrays and pointers may be nested within a value, so that a corrupt (or
+// malicious) database cannot recurse without bound.
+//
+const MMDB_MAX_DEPTH = 32
+
+const (
+    mmdbExtended  = 0
+    mmdbPointer   = 1
+    mmdbString    = 2
+    mmdbDouble    = 3
+    mmdbBytes     = 4
+    mmdbUint16    = 5
+    mmdbUint32    = 6
+    mmdbMap       = 7
+    mmdbInt32     = 8
+    mmdbUint64    = 9
+    mmdbUint128   = 10
+    mmdbArray     = 11
+    mmdbContainer = 12
+    mmdbEndMarker = 13
+    mmdbBoolean   = 14
+    mmdbFloat     = 15
+)
+
+// A reader for databases in the MaxMind DB format (such as the GeoLite2 Country, City and ASN
+// databases).  The whole file is read into memory, and records are decoded on lookup into
+// plain Go values: maps, slices, strings, numbers and booleans.
+//
+type MaxMindDB struct {
+    Path         string
+    DatabaseType string
+    IPVersion    uint64
+    NodeCount    uint64
+    RecordSize   uint64
+
+    buffer    []byte
+    tree      []byte
+    data      []byte
+    ipv4Start uint64
+}
+
+func OpenMaxMindDB(path string) (*MaxMindDB, error) {
+    if buffer, err := os.ReadFile(path); err == nil {
+        if db, err := NewMaxMindDB(buffer); err == nil {
+            db.Path = path
+            return db, nil
+        }else{
+            return nil, fmt.Errorf("Invalid MaxMind DB file %s: %v", path, err)
+        }
+    }else{
+        return nil, err
+    }
+}
+
+func NewMaxMindDB(buffer []byte) (*MaxMindDB, error) {
+    markerAt := bytes.LastIndex(buffer, MMDB_METADATA_MARKER)
+
+    if markerAt < 0 {
+        return nil, fmt.Errorf("metadata section not found")
+    }
+
+    metadataSection := buffer[markerAt + len(MMDB_METADATA_MARKER):]
+    metadata, _, err := decodeMMDBValue(metadataSection, 0)
+
+    if err != nil {
+        return nil, fmt.Errorf("invalid metadata: %v", err)
+    }
+
+    fields, ok := metadata.(map[string]interface{})
+
+    if !ok {
+        return nil, fmt.Errorf("metadata is not a map")
+    }
+
+    db := &MaxMindDB{
+        buffer: buffer,
+    }
+
+    db.NodeCount, _ = fields[`node_count`].(uint64)
+    db.RecordSize, _ = fields[`record_size`].(uint64)
+    db.IPVersion, _ = fields[`ip_version`].(uint64)
+    db.DatabaseType, _ = fields[`database_type`].(string)
+
+    switch db.RecordSize {
+    case 24, 28, 32:
+    default:
+        return nil, fmt.Errorf("unsupported record size %d", db.RecordSize)
+    }
+
+//  each node holds two records, so is RecordSize / 4 bytes; checked before multiplying so that
+//  a corrupt node count cannot overflow
+    if db.NodeCount > uint64(markerAt) / (db.RecordSize / 4) {
+        return nil, fmt.Errorf("search tree is larger than the file")
+    }
+
+    treeSize := db.NodeCount * db.RecordSize / 4
+
+    if treeSize + MMDB_DATA_SEPARATOR_SIZE > uint64(markerAt) {
+        return nil, fmt.Errorf("search tree is larger than the file")
+    }
+
+    db.tree = buffer[:treeSize]
+    db.data = buffer[treeSize + MMDB_DATA_SEPARATOR_SIZE:markerAt]
+
+//  IPv4 addresses in an IPv6 database live in the ::/96 subtree
+    if db.IPVersion == 6 {
+        for i := 0; i < 96 && db.ipv4Start < db.NodeCount; i++ {
+            db.ipv4Start = db.readRecord(db.ipv4Start, 0)
+        }
+    }
+
+    return db, nil
+}
+
+// Return the record for the network containing the given address, or false if the address
+// is not in the database.
+//
+func (self *MaxMindDB) Lookup(ip net.IP) (interface{}, bool, error) {
+    node := uint64(0)
+
+    if v4 := ip.To4(); v4 != nil {
+        ip = v4
+        node = self.ipv4Start
+    }else if self.IPVersion == 4 {
+        return nil, false, nil
+    }
+
+    for i := 0; i < len(ip) * 8 && node < self.NodeCount; i++ {
+        bit := (ip[i >> 3] >> (7 - uint(i & 7))) & 1
+        node = self.readRecord(node, bit)
+    }
+
+    if node == self.NodeCount {
+        return nil, false, nil
+    }else if node < self.NodeCount {
+        return nil, false, fmt.Errorf("search tree is deeper than the address")
+    }
+
+    offset := node - self.NodeCount - MMDB_DATA_SEPARATOR_SIZE
+
+    if offset >= uint64(len(self.data)) {
+        return nil, false, fmt.Errorf("record offset %d is outside the data section", offset)
+    }
+
+    if value, _, err := decodeMMDBValue(self.data, offset); err == nil {
+        return value, true, nil
+    }else{
+        return nil, false, err
+    }
+}
+
+func (self *MaxMindDB) readRecord(node uint64, bit byte) uint64 {
+    nodeSize := self.RecordSize / 4
+    b := self.tree[node * nodeSize:(node + 1) * nodeSize]
+
+    switch self.RecordSize {
+    case 24:
+        if bit == 0 {
+            return uint64(b[0]) << 16 | uint64(b[1]) << 8 | uint64(b[2])
+        }
+
+        return uint64(b[3]) << 16 | uint64(b[4]) << 8 | uint64(b[5])
+    case 28:
+        if bit == 0 {
+            return uint64(b[3] & 0xF0) << 20 | uint64(b[0]) << 16 | uint64(b[1]) << 8 | uint64(b[2])
+        }
+
+        return uint64(b[3] & 0x0F) << 24 | uint64(b[4]) << 16 | uint64(b[5]) << 8 | uint64(b[6])
+    default:
+        if bit == 0 {
+            return uint64(binary.BigEndian.Uint32(b[0:4]))
+        }
+
+        return uint64(binary.BigEndian.Uint32(b[4:8]))
+    }
+}
+
+// Decode the value at the given offset in a data section, returning it along with the offset
+// immediately following it.
+//
+func decodeMMDBValue(data []byte, offset uint64) (interface{}, uint64, error) {
+    return decodeMMDBValueAt(data, offset, 0)
+}
+
+func decodeMMDBValueAt(data []byte, offset uint64, depth int) (interface{}, uint64, error) {
+    if depth > MMDB_MAX_DEPTH {
+        return nil, offset, fmt.Errorf("value nested more than %d levels deep at offset %d", MMDB_MAX_DEPTH, offset)
+    }
+
+    if offset >= uint64(len(data)) {
+        return nil, offset, fmt.Errorf("unexpected end of data at offset %d", offset)
+    }
+
+    control := data[offset]
+    offset += 1
+    kind := control >> 5
+
+    if kind == mmdbPointer {
+        size := uint64((control >> 3) & 0x3) + 1
+
+        if offset + size > uint64(len(data)) {
+            return nil, offset, fmt.Errorf("truncated pointer at offset %d", offset)
+        }
+
+        var pointer uint64
+
+        if size < 4 {
+            pointer = uint64(control & 0x7)
+        }
+
+        for _, b := range data[offset:offset + size] {
+            pointer = pointer << 8 | uint64(b)
+        }
+
+        switch size {
+        case 2:
+            pointer += 2048
+        case 3:
+            pointer += 526336
+        }
+
+    //  pointers may only refer to values, not to other pointers
+        if pointer < uint64(len(data)) && data[pointer] >> 5 == mmdbPointer {
+            return nil, offset, fmt.Errorf("pointer at offset %d refers to another pointer", offset - 1)
+        }
+
+        value, _, err := decodeMMDBValueAt(data, pointer, depth + 1)
+        return value, offset + size, err
+    }
+
+    if kind == mmdbExtended {
+        if offset >= uint64(len(data)) {
+            return nil, offset, fmt.Errorf("truncated extended type at offset %d", offset)
+        }
+
+        kind = data[offset] + 7
+        offset += 1
+    }
+
+    size := uint64(control & 0x1F)
+
+    if size >= 29 {
+        extra := size - 28
+
+        if offset + extra > uint64(len(data)) {
+            return nil, offset, fmt.Errorf("truncated size at offset %d", offset)
+        }
+
+        var n uint64
+
+        for _, b := range data[offset:offset + extra] {
+            n = n << 8 | uint64(b)
+        }
+
+        switch extra {
+        case 1:
+            size = 29 + n
+        case 2:
+            size = 285 + n
+        default:
+            size = 65821 + n
+        }
+
+        offset += extra
+    }
+
+    remaining := uint64(len(data)) - offset
+
+    switch kind {
+    case mmdbMap:
+    //  every key and value takes at least one byte
+        if size > remaining / 2 {
+            return nil, offset, fmt.Errorf("map of %d entries exceeds the data at offset %d", size, offset)
+        }
+
+        fields := make(map[string]interface{}, size)
+
+        for i := uint64(0); i < size; i++ {
+            var key, value interface{}
+            var err error
+
+            if key, offset, err = decodeMMDBValueAt(data, offset, depth + 1); err != nil {
+                return nil, offset, err
+            }
+
+            if value, offset, err = decodeMMDBValueAt(data, offset, depth + 1); err != nil {
+                return nil, offset, err
+            }
+
+            if name, ok := key.(string); ok {
+                fields[name] = value
+            }else{
+                return nil, offset, fmt.Errorf("map key is not a string")
+            }
+        }
+
+        return fields, offset, nil
+
+    case mmdbArray:
+        if size > remaining {
+            return nil, offset, fmt.Errorf("array of %d values exceeds the data at offset %d", size, offset)
+        }
+
+        values := make([]interface{}, 0, size)
+
+        for i := uint64(0); i < size; i++ {
+            var value interface{}
+            var err error
+
+            if value, offset, err = decodeMMDBValueAt(data, offset, depth + 1); err != nil {
+                return nil, offset, err
+            }
+
+            values = append(values, value)
+        }
+
+        return values, offset, nil
+
+    case mmdbBoolean:
+        return size != 0, offset, nil
+
+    case mmdbContainer, mmdbEndMarker:
+        return nil, offset, nil
+    }
+
+    if offset + size > uint64(len(data)) {
+        return nil, offset, fmt.Errorf("truncated value at offset %d", offset)
+    }
+
+    payload := data[offset:offset + size]
+    offset += size
+
+    switch kind {
+    case mmdbString:
+        return string(payload), offset, nil
+
+    case mmdbBytes, mmdbUint128:
+        return payload, offset, nil
+
+    case mmdbDouble:
+        if size != 8 {
+            return nil, offset, fmt.Errorf("invalid double size %d", size)
+        }
+
+        return math.Float64frombits(binary.BigEndian.Uint64(payload)), offset, nil
+
+    case mmdbFloat:
+        if size != 4 {
+            return nil, offset, fmt.Errorf("invalid float size %d", size)
+        }
+
+        return float64(math.Float32frombits(binary.BigEndian.Uint32(payload))), offset, nil
+
+    case mmdbUint16, mmdbUint32, mmdbUint64:
+        var n uint64
+
+        for _, b := range payload {
+            n = n << 8 | uint64(b)
+        }
+
+        return n, offset, nil
+
+    case mmdbInt32:
+        var n uint32
+
+        for _, b := range payload {
+            n = n << 8 | uint32(b)
+        }
+
+        return int64(int32(n)), offset, nil
+    }
+
+    return nil, offset, fmt.Errorf("unknown data type %d", kind)
+}
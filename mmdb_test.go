@@ -0,0 +1,310 @@
+package main
+
+import (
+    "bytes"
+    "encoding/binary"
+    "math"
+    "net"
+    "sort"
+    "testing"
+)
+
+type testMMDBEntry struct {
+    Network string
+    Record  map[string]interface{}
+}
+
+func encodeTestMMDBHeader(kind byte, size int) []byte {
+    header := []byte{ 0 }
+
+    if kind < 8 {
+        header[0] = kind << 5
+    }else{
+        header = append(header, kind - 7)
+    }
+
+    switch {
+    case size < 29:
+        header[0] |= byte(size)
+    case size < 285:
+        header[0] |= 29
+        header = append(header, byte(size - 29))
+    default:
+        header[0] |= 30
+        header = append(header, byte((size - 285) >> 8), byte(size - 285))
+    }
+
+    return header
+}
+
+func encodeTestMMDBValue(value interface{}) []byte {
+    switch v := value.(type) {
+    case string:
+        return append(encodeTestMMDBHeader(mmdbString, len(v)), v...)
+
+    case uint64:
+        payload := make([]byte, 8)
+        binary.BigEndian.PutUint64(payload, v)
+        payload = bytes.TrimLeft(payload, "\x00")
+
+        if len(payload) <= 4 {
+            return append(encodeTestMMDBHeader(mmdbUint32, len(payload)), payload...)
+        }
+
+        return append(encodeTestMMDBHeader(mmdbUint64, len(payload)), payload...)
+
+    case float64:
+        payload := make([]byte, 8)
+        binary.BigEndian.PutUint64(payload, math.Float64bits(v))
+        return append(encodeTestMMDBHeader(mmdbDouble, 8), payload...)
+
+    case bool:
+        if v {
+            return encodeTestMMDBHeader(mmdbBoolean, 1)
+        }
+
+        return encodeTestMMDBHeader(mmdbBoolean, 0)
+
+    case []interface{}:
+        encoded := encodeTestMMDBHeader(mmdbArray, len(v))
+
+        for _, item := range v {
+            encoded = append(encoded, encodeTestMMDBValue(item)...)
+        }
+
+        return encoded
+
+    case map[string]interface{}:
+        keys := make([]string, 0, len(v))
+
+        for key, _ := range v {
+            keys = append(keys, key)
+        }
+
+        sort.Strings(keys)
+
+        encoded := encodeTestMMDBHeader(mmdbMap, len(v))
+
+        for _, key := range keys {
+            encoded = append(encoded, encodeTestMMDBValue(key)...)
+            encoded = append(encoded, encodeTestMMDBValue(v[key])...)
+        }
+
+        return encoded
+    }
+
+    panic("unsupported test value")
+}
+
+// Build a MaxMind DB file containing the given (non-overlapping) networks.
+//
+func buildTestMMDB(ipVersion int, recordSize int, entries []testMMDBEntry) []byte {
+    const empty = -1
+
+    nodes := [][2]int64{ { empty, empty } }
+    data := make([]byte, 0)
+    offsets := make([]int, 0)
+
+    for _, entry := range entries {
+        _, network, err := net.ParseCIDR(entry.Network)
+
+        if err != nil {
+            panic(err)
+        }
+
+        ip := network.IP
+        prefix, _ := network.Mask.Size()
+
+        if v4 := ip.To4(); v4 != nil && ipVersion == 6 {
+            ip = append(make(net.IP, 12), v4...)
+            prefix += 96
+        }
+
+        offsets = append(offsets, len(data))
+        data = append(data, encodeTestMMDBValue(entry.Record)...)
+
+        node := 0
+
+        for i := 0; i < prefix; i++ {
+            bit := (ip[i >> 3] >> (7 - uint(i & 7))) & 1
+
+            if i == prefix - 1 {
+                nodes[node][bit] = -2 - int64(len(offsets) - 1)
+            }else{
+                if nodes[node][bit] == empty {
+                    nodes = append(nodes, [2]int64{ empty, empty })
+                    nodes[node][bit] = int64(len(nodes) - 1)
+                }
+
+                node = int(nodes[node][bit])
+            }
+        }
+    }
+
+    nodeCount := uint64(len(nodes))
+    buffer := make([]byte, 0)
+
+    resolve := func(record int64) uint64 {
+        switch {
+        case record == empty:
+            return nodeCount
+        case record < empty:
+            return nodeCount + MMDB_DATA_SEPARATOR_SIZE + uint64(offsets[-2 - record])
+        default:
+            return uint64(record)
+        }
+    }
+
+    for _, node := range nodes {
+        left := resolve(node[0])
+        right := resolve(node[1])
+
+        switch recordSize {
+        case 24:
+            buffer = append(buffer, byte(left >> 16), byte(left >> 8), byte(left), byte(right >> 16), byte(right >> 8), byte(right))
+        case 28:
+            buffer = append(buffer, byte(left >> 16), byte(left >> 8), byte(left), byte((left >> 24) << 4 | (right >> 24)), byte(right >> 16), byte(right >> 8), byte(right))
+        case 32:
+            buffer = binary.BigEndian.AppendUint32(buffer, uint32(left))
+            buffer = binary.BigEndian.AppendUint32(buffer, uint32(right))
+        }
+    }
+
+    buffer = append(buffer, make([]byte, MMDB_DATA_SEPARATOR_SIZE)...)
+    buffer = append(buffer, data...)
+    buffer = append(buffer, MMDB_METADATA_MARKER...)
+    buffer = append(buffer, encodeTestMMDBValue(map[string]interface{}{
+        `node_count`:                  nodeCount,
+        `record_size`:                 uint64(recordSize),
+        `ip_version`:                  uint64(ipVersion),
+        `database_type`:               `Test`,
+        `binary_format_major_version`: uint64(2),
+        `binary_format_minor_version`: uint64(0),
+    })...)
+
+    return buffer
+}
+
+func TestMaxMindDBLookup(t *testing.T) {
+    entries := []testMMDBEntry{
+        { `203.0.113.0/24`,  map[string]interface{}{ `name`: `documentation`, `score`: 1.5, `tags`: []interface{}{ `a`, true } } },
+        { `198.51.100.0/25`, map[string]interface{}{ `name`: `lower` } },
+        { `2001:db8::/32`,   map[string]interface{}{ `name`: `ipv6` } },
+    }
+
+    for _, ipVersion := range []int{ 4, 6 } {
+        for _, recordSize := range []int{ 24, 28, 32 } {
+            var db *MaxMindDB
+            var err error
+
+            if ipVersion == 4 {
+                db, err = NewMaxMindDB(buildTestMMDB(ipVersion, recordSize, entries[:2]))
+            }else{
+                db, err = NewMaxMindDB(buildTestMMDB(ipVersion, recordSize, entries))
+            }
+
+            if err != nil {
+                t.Fatalf("Failed to open IPv%d database with %d-bit records: %v", ipVersion, recordSize, err)
+            }
+
+            if db.DatabaseType != `Test` || db.RecordSize != uint64(recordSize) {
+                t.Errorf("Incorrect metadata: %+v", db)
+            }
+
+            expected := map[string]string{
+                `203.0.113.77`:   `documentation`,
+                `198.51.100.10`:  `lower`,
+                `198.51.100.200`: ``,
+                `192.0.2.1`:      ``,
+            }
+
+            if ipVersion == 6 {
+                expected[`2001:db8::1`] = `ipv6`
+                expected[`2001:db9::1`] = ``
+            }
+
+            for address, name := range expected {
+                record, ok, err := db.Lookup(net.ParseIP(address))
+
+                if err != nil {
+                    t.Errorf("Failed to look up %s: %v", address, err)
+                }else if name == `` {
+                    if ok {
+                        t.Errorf("Expected %s not to be found, got %v", address, record)
+                    }
+                }else if fields, isMap := record.(map[string]interface{}); !ok || !isMap || fields[`name`] != name {
+                    t.Errorf("Expected %s to be found as '%s', got %v", address, name, record)
+                }
+            }
+
+            if record, _, _ := db.Lookup(net.ParseIP(`203.0.113.1`)); record != nil {
+                fields := record.(map[string]interface{})
+
+                if fields[`score`] != 1.5 {
+                    t.Errorf("Expected a double of 1.5, got %v", fields[`score`])
+                }
+
+                if tags, ok := fields[`tags`].([]interface{}); !ok || len(tags) != 2 || tags[0] != `a` || tags[1] != true {
+                    t.Errorf("Expected an array of [a true], got %v", fields[`tags`])
+                }
+            }
+        }
+    }
+}
+
+func TestMaxMindDBPointers(t *testing.T) {
+    data := encodeTestMMDBValue(`hello`)
+    pointerAt := uint64(len(data))
+    data = append(data, 0x20, 0x00)
+
+    if value, next, err := decodeMMDBValue(data, pointerAt); err != nil {
+        t.Errorf("Failed to decode pointer: %v", err)
+    }else if value != `hello` || next != pointerAt + 2 {
+        t.Errorf("Expected pointer to resolve to 'hello' and be followed by offset %d, got %v and %d", pointerAt + 2, value, next)
+    }
+}
+
+func TestMaxMindDBInvalid(t *testing.T) {
+    if _, err := NewMaxMindDB([]byte(`not a database`)); err == nil {
+        t.Errorf("Expected an error opening a file without metadata")
+    }
+}
+
+func TestMaxMindDBCorrupt(t *testing.T) {
+//  a pointer to itself (and so to another pointer)
+    if _, _, err := decodeMMDBValue([]byte{ 0x20, 0x00 }, 0); err == nil {
+        t.Errorf("Expected an error decoding a pointer to a pointer")
+    }
+
+//  arrays of one value, nested more deeply than allowed
+    nested := make([]byte, 0)
+
+    for i := 0; i <= MMDB_MAX_DEPTH + 1; i++ {
+        nested = append(nested, 0x01, 0x04)
+    }
+
+    if _, _, err := decodeMMDBValue(append(nested, 0x40), 0); err == nil {
+        t.Errorf("Expected an error decoding deeply nested arrays")
+    }
+
+//  a map and an array claiming far more entries than there are bytes
+    if _, _, err := decodeMMDBValue([]byte{ 0xFF, 0xFF, 0xFF, 0xFF, 0x40, 0x40 }, 0); err == nil {
+        t.Errorf("Expected an error decoding an oversized map")
+    }
+
+    if _, _, err := decodeMMDBValue([]byte{ 0x1F, 0x04, 0xFF, 0xFF, 0xFF, 0x40 }, 0); err == nil {
+        t.Errorf("Expected an error decoding an oversized array")
+    }
+
+//  a node count so large that the size of the search tree would overflow
+    buffer := append(make([]byte, 64), MMDB_METADATA_MARKER...)
+    buffer = append(buffer, encodeTestMMDBValue(map[string]interface{}{
+        `node_count`:  uint64(1) << 62,
+        `record_size`: uint64(32),
+        `ip_version`:  uint64(6),
+    })...)
+
+    if _, err := NewMaxMindDB(buffer); err == nil {
+        t.Errorf("Expected an error opening a database with an impossible node count")
+    }
+}
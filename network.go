@@ -0,0 +1,192 @@
+package main
+
+import (
+    "fmt"
+    "net"
+    "strings"
+)
+
+const DEFAULT_IPV4_PREFIX = 24
+const DEFAULT_IPV6_PREFIX = 64
+
+// Parse a list of networks in CIDR notation.  Bare addresses are treated as a network
+// containing only that address.
+//
+func ParseNetworks(specs []string) ([]*net.IPNet, error) {
+    networks := make([]*net.IPNet, 0)
+
+    for _, spec := range specs {
+        for _, value := range strings.Split(spec, `,`) {
+            value = strings.TrimSpace(value)
+
+            if value == `` {
+                continue
+            }
+
+            if !strings.Contains(value, `/`) {
+                if ip := net.ParseIP(value); ip == nil {
+                    return nil, fmt.Errorf("Invalid network '%s'", value)
+                }else if ip.To4() != nil {
+                    value = value + `/32`
+                }else{
+                    value = value + `/128`
+                }
+            }
+
+            if _, network, err := net.ParseCIDR(value); err == nil {
+                networks = append(networks, network)
+            }else{
+                return nil, fmt.Errorf("Invalid network '%s': %v", value, err)
+            }
+        }
+    }
+
+    return networks, nil
+}
+
+// A NetworkFilter decides which client addresses are included in statistics.  If any allowed
+// networks are given, only addresses within them are permitted; addresses within any denied
+// network are always excluded (e.g.: to ignore internal health checks and monitors).
+//
+type NetworkFilter struct {
+    Allow []*net.IPNet
+    Deny  []*net.IPNet
+}
+
+// Return whether requests from the given address should be counted.  Addresses that cannot be
+// parsed are only permitted if there is no allow list.
+//
+func (self *NetworkFilter) Permits(address string) bool {
+    if self == nil || (len(self.Allow) == 0 && len(self.Deny) == 0) {
+        return true
+    }
+
+    ip := net.ParseIP(address)
+
+    if ip == nil {
+        return len(self.Allow) == 0
+    }
+
+    for _, network := range self.Deny {
+        if network.Contains(ip) {
+            return false
+        }
+    }
+
+    if len(self.Allow) == 0 {
+        return true
+    }
+
+    for _, network := range self.Allow {
+        if network.Contains(ip) {
+            return true
+        }
+    }
+
+    return false
+}
+
+// Return the network (in CIDR notation) containing the given address, using the given prefix
+// lengths for IPv4 and IPv6 addresses respectively.
+//
+func NetworkPrefix(address string, ipv4Bits int, ipv6Bits int) (string, bool) {
+    ip := net.ParseIP(address)
+
+    if ip == nil {
+        return ``, false
+    }
+
+    if v4 := ip.To4(); v4 != nil {
+        return (&net.IPNet{
+            IP:   v4.Mask(net.CIDRMask(ipv4Bits, 32)),
+            Mask: net.CIDRMask(ipv4Bits, 32),
+        }).String(), true
+    }
+
+    return (&net.IPNet{
+        IP:   ip.Mask(net.CIDRMask(ipv6Bits, 128)),
+        Mask: net.CIDRMask(ipv6Bits, 128),
+    }).String(), true
+}
+
+// Fields (from custom log formats) that may contain the chain of addresses a request was
+// forwarded through, checked in order.  The third quoted value of a log line (as written by
+// nginx's default "main" format) is also treated as X-Forwarded-For.
+//
+var FORWARDED_FOR_FIELDS = []string{
+    `http_x_forwarded_for`,
+    `x_forwarded_for`,
+    `x-forwarded-for`,
+    `xff`,
+    `forwarded_for`,
+}
+
+// Requests received from addresses within these networks are assumed to have been forwarded by
+// a proxy or load balancer, and the real client address is taken from the forwarded-for chain.
+//
+var TrustedProxies []*net.IPNet
+
+func IsTrustedProxy(address string) bool {
+    if ip := net.ParseIP(address); ip != nil {
+        for _, network := range TrustedProxies {
+            if network.Contains(ip) {
+                return true
+            }
+        }
+    }
+
+    return false
+}
+
+// Determine the real client address of a request that was received from the given peer with
+// the given forwarded-for chain.  The chain is walked right-to-left (most recent hop first) for
+// as long as each hop is a trusted proxy; the first untrusted address is the client.  If every
+// hop is trusted, the leftmost address is used.
+//
+func ResolveClientAddress(peer string, forwardedFor string) string {
+    if len(TrustedProxies) == 0 || forwardedFor == `` || !IsTrustedProxy(peer) {
+        return peer
+    }
+
+    hops := strings.Split(forwardedFor, `,`)
+    client := peer
+
+    for i := len(hops) - 1; i >= 0; i-- {
+        hop := normalizeForwardedAddress(hops[i])
+
+        if hop == `` {
+            continue
+        }
+
+    //  an unparseable hop can't be trusted to have been written by one of our proxies
+        if net.ParseIP(hop) == nil {
+            break
+        }
+
+        client = hop
+
+        if !IsTrustedProxy(hop) {
+            break
+        }
+    }
+
+    return client
+}
+
+// Strip any port, brackets and quoting from a single forwarded-for hop.
+//
+func normalizeForwardedAddress(hop string) string {
+    hop = strings.Trim(strings.TrimSpace(hop), `"`)
+
+    if strings.HasPrefix(hop, `[`) {
+        if end := strings.Index(hop, `]`); end > 0 {
+            return hop[1:end]
+        }
+    }
+
+    if host, _, err := net.SplitHostPort(hop); err == nil {
+        return host
+    }
+
+    return hop
+}
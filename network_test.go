@@ -0,0 +1,118 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestNetworkPrefix(t *testing.T) {
+    expected := map[string]string{
+        `160.247.141.114`:      `160.247.141.0/24`,
+        `2001:db8:1:2:3:4:5:6`: `2001:db8:1:2::/64`,
+        `::ffff:10.1.2.3`:      `10.1.2.0/24`,
+    }
+
+    for address, network := range expected {
+        if v, ok := NetworkPrefix(address, 24, 64); !ok || v != network {
+            t.Errorf("Expected %s to be in %s, got '%s'", address, network, v)
+        }
+    }
+
+    if _, ok := NetworkPrefix(`example.com`, 24, 64); ok {
+        t.Errorf("Expected a hostname to have no network")
+    }
+}
+
+func TestNetworkFilter(t *testing.T) {
+    deny, err := ParseNetworks([]string{ `10.0.0.0/8,192.168.1.5`, `fd00::/8` })
+
+    if err != nil {
+        t.Fatalf("Failed to parse networks: %v", err)
+    }
+
+    filter := &NetworkFilter{
+        Deny: deny,
+    }
+
+    for address, permitted := range map[string]bool{
+        `10.1.2.3`:    false,
+        `192.168.1.5`: false,
+        `192.168.1.6`: true,
+        `fd00::1`:     false,
+        `2001:db8::1`: true,
+        `example.com`: true,
+    }{
+        if filter.Permits(address) != permitted {
+            t.Errorf("Expected Permits(%s) to be %v", address, permitted)
+        }
+    }
+
+    filter.Allow, _ = ParseNetworks([]string{ `192.168.0.0/16` })
+
+    if filter.Permits(`8.8.8.8`) || !filter.Permits(`192.168.1.6`) || filter.Permits(`example.com`) {
+        t.Errorf("Expected only addresses in the allow list to be permitted")
+    }
+
+    if _, err := ParseNetworks([]string{ `not-a-network` }); err == nil {
+        t.Errorf("Expected an invalid network to be rejected")
+    }
+}
+
+func TestResolveClientAddress(t *testing.T) {
+    if networks, err := ParseNetworks([]string{ `10.0.0.0/8`, `2001:db8::/32` }); err == nil {
+        TrustedProxies = networks
+        defer func(){ TrustedProxies = nil }()
+    }else{
+        t.Fatalf("Failed to parse networks: %v", err)
+    }
+
+    for _, testCase := range [][]string{
+        { `10.0.0.1`,    `203.0.113.7`,                         `203.0.113.7` },
+        { `10.0.0.1`,    `203.0.113.7, 10.1.1.1, 10.2.2.2`,     `203.0.113.7` },
+        { `10.0.0.1`,    `198.51.100.1, 203.0.113.7, 10.1.1.1`, `203.0.113.7` },
+        { `10.0.0.1`,    `10.9.9.9, 10.1.1.1`,                  `10.9.9.9` },
+        { `10.0.0.1`,    `"203.0.113.7:51234"`,                 `203.0.113.7` },
+        { `10.0.0.1`,    `[2001:db9::1]:443`,                   `2001:db9::1` },
+        { `10.0.0.1`,    `unknown, 10.1.1.1`,                   `10.1.1.1` },
+        { `10.0.0.1`,    ``,                                    `10.0.0.1` },
+        { `192.0.2.50`,  `203.0.113.7`,                         `192.0.2.50` },
+        { `2001:db8::1`, `203.0.113.7`,                         `203.0.113.7` },
+    }{
+        if v := ResolveClientAddress(testCase[0], testCase[1]); v != testCase[2] {
+            t.Errorf("Expected client of %s via '%s' to be %s, got %s", testCase[0], testCase[1], testCase[2], v)
+        }
+    }
+}
+
+func TestParseForwardedFor(t *testing.T) {
+    if networks, err := ParseNetworks([]string{ `10.0.0.0/8` }); err == nil {
+        TrustedProxies = networks
+        defer func(){ TrustedProxies = nil }()
+    }else{
+        t.Fatalf("Failed to parse networks: %v", err)
+    }
+
+    for line, client := range map[string]string{
+        `10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET / HTTP/1.1" 200 512 "-" "curl/7.0" "203.0.113.7, 10.1.1.1"`: `203.0.113.7`,
+        `10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET / HTTP/1.1" 200 512 xff="198.51.100.1"`:                     `198.51.100.1`,
+        `192.0.2.1 - - [15/Mar/2016:22:58:38 -0400] "GET / HTTP/1.1" 200 512 xff="198.51.100.1"`:                    `192.0.2.1`,
+    }{
+        logLine := NcsaLog{}
+
+        if err := logLine.Parse(line); err != nil {
+            t.Fatalf("Failed to parse log line: %v", err)
+        }
+
+        if v := logLine.ClientAddress(); v != client {
+            t.Errorf("Expected client address %s, got %s", client, v)
+        }
+
+    //  the "client" grouping follows the forwarded-for chain, while "host" remains the peer
+        for expression, expected := range map[string]string{ `client`: client, `host`: logLine.Host } {
+            grouping, _ := ParseGrouping(expression)
+
+            if v, _ := grouping.Key(&logLine); v != expected {
+                t.Errorf("Expected '%s' grouping key %s, got %s", expression, expected, v)
+            }
+        }
+    }
+}
@@ -0,0 +1,716 @@
+package main
+
+import (
+    "encoding/csv"
+    "encoding/json"
+    "fmt"
+    "io"
+    "sort"
+    "strconv"
+    "strings"
+    "time"
+)
+
+const OUTPUT_TEXT = `text`
+const OUTPUT_JSON = `json`
+const OUTPUT_CSV  = `csv`
+
+// The machine-readable form of a LogStatistic, with all values as raw numbers.
+//
+type SectionRecord struct {
+    Key            string                 `json:"key"`
+    Count          uint64                 `json:"count"`
+    Statuses       map[string]uint64      `json:"statuses"`
+    StatusCodes    map[string]uint64      `json:"status_codes"`
+    Methods        map[string]uint64      `json:"methods"`
+    Protocols      map[string]uint64      `json:"protocols"`
+    Countries      map[string]uint64      `json:"countries,omitempty"`
+    ASNs           map[string]uint64      `json:"asns,omitempty"`
+    CacheStatuses  map[string]uint64      `json:"cache_statuses,omitempty"`
+    CacheHitRatio  *float64               `json:"cache_hit_ratio,omitempty"`
+    ErrorRatio     float64                `json:"error_ratio"`
+    Bytes          uint64                 `json:"bytes"`
+    BytesPerSecond float64                `json:"bytes_per_second"`
+    BandwidthShare float64                `json:"bandwidth_share"`
+    SizeQuantiles  map[string]float64     `json:"size_quantiles,omitempty"`
+    TimeQuantiles  map[string]float64     `json:"time_quantiles,omitempty"`
+    UniqueHosts    uint64                 `json:"unique_hosts"`
+    UniqueClients  uint64                 `json:"unique_clients"`
+    UniquePaths    uint64                 `json:"unique_paths"`
+    UniqueSections uint64                 `json:"unique_sections"`
+    Trends         map[string]TrendRecord `json:"trends,omitempty"`
+}
+
+func NewSectionRecord(stat *LogStatistic, totals *LogStatistic, elapsed time.Duration) SectionRecord {
+    record := SectionRecord{
+        Key:            stat.Key,
+        Count:          stat.Count,
+        Statuses:       stat.GroupByStatusFamily(),
+        StatusCodes:    make(map[string]uint64),
+        Methods:        stat.Methods,
+        Protocols:      stat.Protocols,
+        Countries:      stat.Countries,
+        ASNs:           stat.ASNs,
+        ErrorRatio:     stat.ErrorRatio(),
+        Bytes:          stat.Bytes,
+        SizeQuantiles:  quantileMap(stat.Sizes),
+        TimeQuantiles:  quantileMap(stat.Durations),
+        UniqueHosts:    stat.Hosts.Count(),
+        UniqueClients:  stat.Clients.Count(),
+        UniquePaths:    stat.Paths.Count(),
+        UniqueSections: stat.Sections.Count(),
+    }
+
+//  breakdowns are only allocated once they see a value, but are always output
+    if record.Methods == nil {
+        record.Methods = make(map[string]uint64)
+    }
+
+    if record.Protocols == nil {
+        record.Protocols = make(map[string]uint64)
+    }
+
+    if ratio, ok := stat.CacheHitRatio(); ok {
+        record.CacheStatuses = stat.Caches
+        record.CacheHitRatio = &ratio
+    }
+
+    for code, count := range stat.Statuses {
+        record.StatusCodes[strconv.FormatUint(uint64(code), 10)] = count
+    }
+
+    if elapsed > 0 {
+        record.BytesPerSecond = float64(stat.Bytes) / elapsed.Seconds()
+    }
+
+    if totals != nil && totals.Bytes > 0 {
+        record.BandwidthShare = float64(stat.Bytes) / float64(totals.Bytes)
+    }
+
+    return record
+}
+
+// The bandwidth consumed by a single client, as estimated by a heavy hitters sketch.
+//
+type BandwidthRecord struct {
+    Client         string  `json:"client"`
+    Bytes          uint64  `json:"bytes"`
+    Error          uint64  `json:"error"`
+    BytesPerSecond float64 `json:"bytes_per_second"`
+    Share          float64 `json:"share"`
+}
+
+func NewBandwidthRecords(sketch *SpaceSaving, n int, elapsed time.Duration) []BandwidthRecord {
+    records := make([]BandwidthRecord, 0)
+
+    if sketch == nil {
+        return records
+    }
+
+    for _, hitter := range sketch.Top(n) {
+        record := BandwidthRecord{
+            Client: hitter.Key,
+            Bytes:  hitter.Count,
+            Error:  hitter.Error,
+        }
+
+        if elapsed > 0 {
+            record.BytesPerSecond = float64(hitter.Count) / elapsed.Seconds()
+        }
+
+        if sketch.Total > 0 {
+            record.Share = float64(hitter.Count) / float64(sketch.Total)
+        }
+
+        records = append(records, record)
+    }
+
+    return records
+}
+
+// A referring domain and how many requests it referred.  Requests without a referer are
+// reported under the domain "-", with a type of "direct".
+//
+type RefererRecord struct {
+    Domain string  `json:"domain"`
+    Type   string  `json:"type"`
+    Count  uint64  `json:"count"`
+    Error  uint64  `json:"error"`
+    Share  float64 `json:"share"`
+}
+
+func NewRefererRecords(report *RefererReport, n int) []RefererRecord {
+    records := make([]RefererRecord, 0)
+
+    if report == nil {
+        return records
+    }
+
+    total := report.Total()
+
+    if report.Direct > 0 {
+        records = append(records, RefererRecord{
+            Domain: `-`,
+            Type:   REFERER_DIRECT,
+            Count:  report.Direct,
+        })
+    }
+
+    for kind, sketch := range map[string]*SpaceSaving{ REFERER_INTERNAL: report.Internal, REFERER_EXTERNAL: report.External } {
+        for _, hitter := range sketch.Top(n) {
+            records = append(records, RefererRecord{
+                Domain: hitter.Key,
+                Type:   kind,
+                Count:  hitter.Count,
+                Error:  hitter.Error,
+            })
+        }
+    }
+
+    sort.Slice(records, func(i, j int) bool {
+        if records[i].Count == records[j].Count {
+            return records[i].Domain < records[j].Domain
+        }
+
+        return records[i].Count > records[j].Count
+    })
+
+    if len(records) > n {
+        records = records[:n]
+    }
+
+    for i := range records {
+        if total > 0 {
+            records[i].Share = float64(records[i].Count) / float64(total)
+        }
+    }
+
+    return records
+}
+
+// An inbound link (from the referer to the path) that resulted in a 404.
+//
+type BrokenLinkRecord struct {
+    Referer string `json:"referer"`
+    Path    string `json:"path"`
+    Count   uint64 `json:"count"`
+    Error   uint64 `json:"error"`
+}
+
+func NewBrokenLinkRecords(report *RefererReport, n int) []BrokenLinkRecord {
+    records := make([]BrokenLinkRecord, 0)
+
+    if report == nil {
+        return records
+    }
+
+    for _, hitter := range report.BrokenLinks.Top(n) {
+        parts := strings.SplitN(hitter.Key, BROKEN_LINK_SEPARATOR, 2)
+
+        if len(parts) == 2 {
+            records = append(records, BrokenLinkRecord{
+                Referer: RedactValue(parts[0]),
+                Path:    parts[1],
+                Count:   hitter.Count,
+                Error:   hitter.Error,
+            })
+        }
+    }
+
+    return records
+}
+
+// Metrics over the sessions that ended within an interval.
+//
+type SessionRecord struct {
+    Sessions          uint64             `json:"sessions"`
+    Active            int                `json:"active"`
+    BounceRate        float64            `json:"bounce_rate"`
+    PagesPerSession   float64            `json:"pages_per_session"`
+    PageQuantiles     map[string]float64 `json:"page_quantiles,omitempty"`
+    DurationQuantiles map[string]float64 `json:"duration_quantiles,omitempty"`
+    EntryPages        []PageRecord       `json:"entry_pages"`
+    ExitPages         []PageRecord       `json:"exit_pages"`
+}
+
+// A page and the (approximate) number of sessions that entered or exited on it.
+//
+type PageRecord struct {
+    Path  string `json:"path"`
+    Count uint64 `json:"count"`
+    Error uint64 `json:"error"`
+}
+
+func NewSessionRecord(stats *SessionStats, n int) *SessionRecord {
+    if stats == nil {
+        return nil
+    }
+
+    return &SessionRecord{
+        Sessions:          stats.Count,
+        BounceRate:        stats.BounceRate(),
+        PagesPerSession:   stats.Pages.Mean(),
+        PageQuantiles:     quantileMap(stats.Pages),
+        DurationQuantiles: quantileMap(stats.Durations),
+        EntryPages:        newPageRecords(stats.EntryPages, n),
+        ExitPages:         newPageRecords(stats.ExitPages, n),
+    }
+}
+
+func newPageRecords(sketch *SpaceSaving, n int) []PageRecord {
+    records := make([]PageRecord, 0)
+
+    for _, hitter := range sketch.Top(n) {
+        records = append(records, PageRecord{
+            Path:  hitter.Key,
+            Count: hitter.Count,
+            Error: hitter.Error,
+        })
+    }
+
+    return records
+}
+
+// How many sessions reached one step of a funnel.  Conversion is the share of sessions that
+// reached the first step which went on to reach this one, and DropOff is the number of
+// sessions that reached the previous step but not this one.
+//
+type FunnelStepRecord struct {
+    Step       string  `json:"step"`
+    Sessions   uint64  `json:"sessions"`
+    Conversion float64 `json:"conversion"`
+    DropOff    uint64  `json:"drop_off"`
+}
+
+func NewFunnelRecords(funnel *Funnel, stats *SessionStats) []FunnelStepRecord {
+    records := make([]FunnelStepRecord, len(funnel.Steps))
+
+    for i, step := range funnel.Steps {
+        records[i].Step = step
+
+        if stats != nil && i < len(stats.Funnel) {
+            records[i].Sessions = stats.Funnel[i]
+        }
+
+        if records[0].Sessions > 0 {
+            records[i].Conversion = float64(records[i].Sessions) / float64(records[0].Sessions)
+        }
+
+        if i > 0 {
+            records[i].DropOff = records[i - 1].Sessions - records[i].Sessions
+        }
+    }
+
+    return records
+}
+
+// How often a query string parameter was used, and how many distinct values it had.  The most
+// common values are only included for parameters that were selected for value tracking.
+//
+type QueryParamRecord struct {
+    Name           string        `json:"name"`
+    Count          uint64        `json:"count"`
+    Error          uint64        `json:"error"`
+    Share          float64       `json:"share"`
+    DistinctValues uint64        `json:"distinct_values"`
+    TopValues      []ValueRecord `json:"top_values,omitempty"`
+}
+
+type ValueRecord struct {
+    Value string `json:"value"`
+    Count uint64 `json:"count"`
+    Error uint64 `json:"error"`
+}
+
+// Return records for the n most frequently used parameters, followed by any parameters selected
+// for value tracking that were not among them.
+//
+func NewQueryParamRecords(report *QueryReport, n int) []QueryParamRecord {
+    records := make([]QueryParamRecord, 0)
+
+    if report == nil {
+        return records
+    }
+
+    included := make(map[string]bool)
+    hitters := report.Names.Top(n)
+
+    for _, name := range report.ValueParams() {
+        if hitter, ok := report.Names.Get(name); ok {
+            hitters = append(hitters, hitter)
+        }
+    }
+
+    for _, hitter := range hitters {
+        if included[hitter.Key] {
+            continue
+        }
+
+        record := QueryParamRecord{
+            Name:  hitter.Key,
+            Count: hitter.Count,
+            Error: hitter.Error,
+        }
+
+        if report.Requests > 0 {
+            record.Share = float64(hitter.Count) / float64(report.Requests)
+        }
+
+        if cardinality, ok := report.Cardinality[hitter.Key]; ok {
+            record.DistinctValues = cardinality.Count()
+        }
+
+    //  the values of redacted parameters are never shown
+        if sketch, ok := report.Values[hitter.Key]; ok && !RedactedParams[strings.ToLower(hitter.Key)] {
+            for _, value := range sketch.Top(n) {
+                record.TopValues = append(record.TopValues, ValueRecord{
+                    Value: value.Key,
+                    Count: value.Count,
+                    Error: value.Error,
+                })
+            }
+        }
+
+        included[hitter.Key] = true
+        records = append(records, record)
+    }
+
+    return records
+}
+
+// How many entries to include in each of the optional parts of a summary (zero to omit it),
+// and the funnel to report on (if any).
+//
+type ReportOptions struct {
+    TopBandwidth int
+    TopReferers  int
+    TopPages     int
+    TopParams    int
+    Funnel       *Funnel
+}
+
+// Everything reported at the end of one interval.
+//
+type SummaryRecord struct {
+    Time         time.Time          `json:"time"`
+    Seconds      float64            `json:"seconds"`
+    Totals       SectionRecord      `json:"totals"`
+    Sections     []SectionRecord    `json:"sections"`
+    TopBandwidth []BandwidthRecord  `json:"top_bandwidth,omitempty"`
+    Referers     []RefererRecord    `json:"referers,omitempty"`
+    BrokenLinks  []BrokenLinkRecord `json:"broken_links,omitempty"`
+    Sessions     *SessionRecord     `json:"sessions,omitempty"`
+    Funnel       []FunnelStepRecord `json:"funnel,omitempty"`
+    QueryParams  []QueryParamRecord `json:"query_params,omitempty"`
+}
+
+func NewSummaryRecord(tick time.Time, summary *Bucket, sections []*LogStatistic, options ReportOptions) SummaryRecord {
+    elapsed := summary.Elapsed()
+
+    record := SummaryRecord{
+        Time:     tick.In(TimestampLocation),
+        Seconds:  elapsed.Seconds(),
+        Totals:   NewSectionRecord(summary.Totals, summary.Totals, elapsed),
+        Sections: make([]SectionRecord, len(sections)),
+    }
+
+    for i, section := range sections {
+        record.Sections[i] = NewSectionRecord(section, summary.Totals, elapsed)
+    }
+
+    if options.TopBandwidth > 0 {
+        record.TopBandwidth = NewBandwidthRecords(summary.ClientBytes, options.TopBandwidth, elapsed)
+    }
+
+    if options.TopReferers > 0 {
+        record.Referers = NewRefererRecords(summary.Referers, options.TopReferers)
+        record.BrokenLinks = NewBrokenLinkRecords(summary.Referers, options.TopReferers)
+    }
+
+    if options.TopPages > 0 {
+        record.Sessions = NewSessionRecord(summary.Sessions, options.TopPages)
+    }
+
+    if options.Funnel != nil {
+        record.Funnel = NewFunnelRecords(options.Funnel, summary.Sessions)
+    }
+
+    if options.TopParams > 0 {
+        record.QueryParams = NewQueryParamRecords(summary.Query, options.TopParams)
+    }
+
+    return record
+}
+
+// Write a summary as a single line of JSON.
+//
+func WriteSummaryJSON(w io.Writer, summary SummaryRecord) error {
+    if data, err := json.Marshal(summary); err == nil {
+        _, err = fmt.Fprintf(w, "%s\n", data)
+        return err
+    }else{
+        return err
+    }
+}
+
+// The machine-readable form of a Heatmap: one matrix per key, each with a row of 24 hourly
+// counts per day of the week.
+//
+type HeatmapRecord struct {
+    By       string                `json:"by"`
+    Timezone string                `json:"timezone"`
+    Matrices []HeatmapMatrixRecord `json:"matrices"`
+}
+
+type HeatmapMatrixRecord struct {
+    Key   string              `json:"key"`
+    Total uint64              `json:"total"`
+    Days  map[string][]uint64 `json:"days"`
+}
+
+func NewHeatmapRecord(heatmap *Heatmap) HeatmapRecord {
+    record := HeatmapRecord{
+        By:       heatmap.By,
+        Timezone: heatmap.Location.String(),
+        Matrices: make([]HeatmapMatrixRecord, 0, len(heatmap.Matrices)),
+    }
+
+    for _, key := range heatmap.Keys() {
+        matrix := heatmap.Matrices[key]
+        days := make(map[string][]uint64)
+
+        for i, weekday := range HEATMAP_WEEKDAYS {
+            days[weekday.String()[:3]] = append([]uint64{}, matrix[i][:]...)
+        }
+
+        record.Matrices = append(record.Matrices, HeatmapMatrixRecord{
+            Key:   key,
+            Total: matrix.Total(),
+            Days:  days,
+        })
+    }
+
+    return record
+}
+
+func WriteHeatmapJSON(w io.Writer, heatmap *Heatmap) error {
+    if data, err := json.Marshal(NewHeatmapRecord(heatmap)); err == nil {
+        _, err = fmt.Fprintf(w, "%s\n", data)
+        return err
+    }else{
+        return err
+    }
+}
+
+// Write a heatmap as CSV, with one row per key and day of the week, and one column per hour.
+//
+func WriteHeatmapCSV(w io.Writer, heatmap *Heatmap) error {
+    writer := csv.NewWriter(w)
+    columns := []string{ `key`, `day` }
+
+    for hour := 0; hour < 24; hour++ {
+        columns = append(columns, fmt.Sprintf("%02d", hour))
+    }
+
+    writer.Write(columns)
+
+    for _, key := range heatmap.Keys() {
+        matrix := heatmap.Matrices[key]
+
+        for i, weekday := range HEATMAP_WEEKDAYS {
+            row := []string{ key, weekday.String()[:3] }
+
+            for _, count := range matrix[i] {
+                row = append(row, formatUint(count))
+            }
+
+            writer.Write(row)
+        }
+    }
+
+    writer.Flush()
+    return writer.Error()
+}
+
+// Write a timeline as CSV, with one row per bucket for all sections together (keyed "*"), and
+// one per section with any requests in that bucket.
+//
+func WriteTimelineCSV(w io.Writer, timeline *Timeline) error {
+    writer := csv.NewWriter(w)
+    families := csvStatusColumns()
+    sections := timeline.Sections()
+
+    writer.Write(append([]string{ `time`, `key`, `count` }, families...))
+
+    for _, start := range timeline.Times() {
+        counts := timeline.Counts(start)
+        total, totals := counts.Totals()
+        row := []string{ start.Format(time.RFC3339Nano), `*`, formatUint(total) }
+
+        for _, family := range families {
+            row = append(row, formatUint(totals[family]))
+        }
+
+        writer.Write(row)
+
+        for _, section := range sections {
+            if count, sectionFamilies := counts.Section(section); count > 0 {
+                row := []string{ start.Format(time.RFC3339Nano), section, formatUint(count) }
+
+                for _, family := range families {
+                    row = append(row, formatUint(sectionFamilies[family]))
+                }
+
+                writer.Write(row)
+            }
+        }
+    }
+
+    writer.Flush()
+    return writer.Error()
+}
+
+var CSV_STATUS_FAMILIES = []string{ `1xx`, `2xx`, `3xx`, `4xx`, `5xx`, `???` }
+
+// Return the status families and any custom status classes, in the order they appear as CSV columns.
+//
+func csvStatusColumns() []string {
+    columns := append([]string{}, CSV_STATUS_FAMILIES...)
+    classes := make([]string, 0)
+    seen := make(map[string]bool)
+
+    for _, class := range StatusClasses {
+        if !seen[class] {
+            classes = append(classes, class)
+            seen[class] = true
+        }
+    }
+
+    sort.Strings(classes)
+
+    return append(columns, classes...)
+}
+
+func WriteSummaryCSVHeader(w io.Writer) error {
+    columns := []string{ `time`, `key`, `count` }
+    columns = append(columns, csvStatusColumns()...)
+    columns = append(columns,
+        `status_codes`, `methods`, `protocols`, `countries`, `asns`,
+        `cache_statuses`, `cache_hit_ratio`, `error_ratio`,
+        `bytes`, `bytes_per_second`, `bandwidth_share`,
+        `size_p50`, `size_p90`, `size_p99`, `size_max`,
+        `time_p50`, `time_p90`, `time_p99`, `time_max`,
+        `unique_hosts`, `unique_clients`, `unique_paths`, `unique_sections`,
+    )
+
+    for _, comparison := range TREND_COMPARISONS {
+        columns = append(columns, `change_` + comparison.Name, `error_ratio_change_` + comparison.Name)
+    }
+
+    writer := csv.NewWriter(w)
+    writer.Write(columns)
+    writer.Flush()
+
+    return writer.Error()
+}
+
+// Write one CSV row per section in the summary.
+//
+func WriteSummaryCSV(w io.Writer, summary SummaryRecord) error {
+    writer := csv.NewWriter(w)
+
+    for _, section := range summary.Sections {
+        row := []string{
+            summary.Time.Format(time.RFC3339Nano),
+            section.Key,
+            formatUint(section.Count),
+        }
+
+        for _, family := range csvStatusColumns() {
+            row = append(row, formatUint(section.Statuses[family]))
+        }
+
+        row = append(row,
+            formatCounts(section.StatusCodes, `;`),
+            formatCounts(section.Methods, `;`),
+            formatCounts(section.Protocols, `;`),
+            formatCounts(section.Countries, `;`),
+            formatCounts(section.ASNs, `;`),
+            formatCounts(section.CacheStatuses, `;`),
+            formatOptionalFloat(section.CacheHitRatio),
+            formatFloat(section.ErrorRatio),
+            formatUint(section.Bytes),
+            formatFloat(section.BytesPerSecond),
+            formatFloat(section.BandwidthShare),
+        )
+
+        for _, quantiles := range []map[string]float64{ section.SizeQuantiles, section.TimeQuantiles } {
+            for _, q := range SUMMARY_QUANTILES {
+                if v, ok := quantiles[quantileName(q)]; ok {
+                    row = append(row, formatFloat(v))
+                }else{
+                    row = append(row, ``)
+                }
+            }
+        }
+
+        row = append(row,
+            formatUint(section.UniqueHosts),
+            formatUint(section.UniqueClients),
+            formatUint(section.UniquePaths),
+            formatUint(section.UniqueSections),
+        )
+
+        for _, comparison := range TREND_COMPARISONS {
+            if trend, ok := section.Trends[comparison.Name]; ok {
+                row = append(row, formatOptionalFloat(trend.Change), formatOptionalFloat(trend.ErrorRatioChange))
+            }else{
+                row = append(row, ``, ``)
+            }
+        }
+
+        writer.Write(row)
+    }
+
+    writer.Flush()
+
+    return writer.Error()
+}
+
+func quantileMap(sketch *QuantileSketch) map[string]float64 {
+    if sketch == nil || sketch.Count == 0 {
+        return nil
+    }
+
+    quantiles := make(map[string]float64)
+
+    for _, q := range SUMMARY_QUANTILES {
+        quantiles[quantileName(q)] = sketch.Quantile(q)
+    }
+
+    return quantiles
+}
+
+func quantileName(q float64) string {
+    if q >= 1 {
+        return `max`
+    }
+
+    return `p` + strconv.FormatFloat(q * 100, 'f', -1, 64)
+}
+
+func formatUint(v uint64) string {
+    return strconv.FormatUint(v, 10)
+}
+
+func formatFloat(v float64) string {
+    return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+func formatOptionalFloat(v *float64) string {
+    if v == nil {
+        return ``
+    }
+
+    return formatFloat(*v)
+}
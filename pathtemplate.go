@@ -0,0 +1,276 @@
+package main
+
+import (
+    "fmt"
+    "regexp"
+    "sort"
+    "strings"
+    "sync"
+)
+
+const LEARNED_PLACEHOLDER = `:param`
+
+// A pattern that, wherever it matches within a path, is replaced with a placeholder.
+//
+type PathPattern struct {
+    Pattern     *regexp.Regexp
+    Placeholder string
+}
+
+// Patterns applied to each individual path segment (in order) when normalizing a path.
+//
+var DEFAULT_SEGMENT_PATTERNS = []PathPattern{
+    { regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`), `:uuid` },
+    { regexp.MustCompile(`^\d+$`),                                                                        `:id`   },
+    { regexp.MustCompile(`^[0-9a-fA-F]{16,}$`),                                                           `:hash` },
+    { regexp.MustCompile(`\d{4,}`),                                                                       `:id`   },
+}
+
+// A PathNormalizer collapses the variable parts of request paths (numeric IDs, UUIDs,
+// hashes and user-supplied patterns) into placeholders, so that paths like "/api/27838" and
+// "/api/4710" are both reported as the route "/api/:id".  It can optionally learn templates
+// from the paths it observes: any position in the path tree that has seen more than a
+// threshold of distinct values is collapsed into a placeholder.  Each request should be
+// observed exactly once (see Observe); everything else that needs its route should use
+// Template, which only looks up what has been learned so far.
+//
+type PathNormalizer struct {
+    Patterns        []PathPattern
+    SegmentPatterns []PathPattern
+
+    learner *routeLearner
+}
+
+func NewPathNormalizer() *PathNormalizer {
+    return &PathNormalizer{
+        Patterns:        make([]PathPattern, 0),
+        SegmentPatterns: DEFAULT_SEGMENT_PATTERNS,
+    }
+}
+
+// The normalizer used by the "route" grouping key.
+//
+var PathTemplates = NewPathNormalizer()
+
+// Add a pattern from a "PLACEHOLDER=REGEX" specification (e.g.: ":sku=[A-Z]{3}-\d+"), which
+// will be applied to the whole path before any per-segment patterns.
+//
+func (self *PathNormalizer) AddPattern(spec string) error {
+    parts := strings.SplitN(spec, `=`, 2)
+
+    if len(parts) != 2 || parts[0] == `` || parts[1] == `` {
+        return fmt.Errorf("Path pattern must be specified as PLACEHOLDER=REGEX, got '%s'", spec)
+    }
+
+    if rx, err := regexp.Compile(parts[1]); err == nil {
+        self.Patterns = append(self.Patterns, PathPattern{
+            Pattern:     rx,
+            Placeholder: parts[0],
+        })
+    }else{
+        return fmt.Errorf("Invalid path pattern '%s': %v", parts[1], err)
+    }
+
+    return nil
+}
+
+// Automatically collapse any path position that has seen more than the given number of
+// distinct values.
+//
+func (self *PathNormalizer) LearnRoutes(threshold int) {
+    self.learner = newRouteLearner(threshold)
+}
+
+// Whether routes are being learned (see LearnRoutes), and so paths need to be observed.
+//
+func (self *PathNormalizer) Learning() bool {
+    return self.learner != nil
+}
+
+// Return the route template for the given path (the query string is discarded), without
+// recording the path for route learning.
+//
+func (self *PathNormalizer) Template(path string) string {
+    segments := self.segments(path)
+
+    if self.learner != nil {
+        self.learner.Lookup(segments)
+    }
+
+    return strings.Join(segments, `/`)
+}
+
+// Record the given path for route learning (if enabled) and return its route template.
+//
+func (self *PathNormalizer) Observe(path string) string {
+    segments := self.segments(path)
+
+    if self.learner != nil {
+        self.learner.Observe(segments)
+    }
+
+    return strings.Join(segments, `/`)
+}
+
+// split a path into segments, replacing those matching any of the patterns with placeholders
+func (self *PathNormalizer) segments(path string) []string {
+    path = RequestPath(path)
+
+    for _, pattern := range self.Patterns {
+        path = pattern.Pattern.ReplaceAllLiteralString(path, pattern.Placeholder)
+    }
+
+    segments := strings.Split(path, `/`)
+
+    for i, segment := range segments {
+        if segment == `` || strings.HasPrefix(segment, `:`) {
+            continue
+        }
+
+        for _, pattern := range self.SegmentPatterns {
+            if pattern.Pattern.MatchString(segment) {
+                segments[i] = pattern.Pattern.ReplaceAllLiteralString(segment, pattern.Placeholder)
+                break
+            }
+        }
+    }
+
+    return segments
+}
+
+// Return the templates learned so far (if learning is enabled), sorted.
+//
+func (self *PathNormalizer) LearnedRoutes() []string {
+    if self.learner == nil {
+        return nil
+    }
+
+    return self.learner.Routes()
+}
+
+type routeNode struct {
+    children  map[string]*routeNode
+    collapsed bool
+}
+
+func newRouteNode() *routeNode {
+    return &routeNode{
+        children: make(map[string]*routeNode),
+    }
+}
+
+// merge another node's subtree into this one
+func (self *routeNode) absorb(other *routeNode) {
+    for segment, child := range other.children {
+        if existing, ok := self.children[segment]; ok {
+            existing.absorb(child)
+        }else{
+            self.children[segment] = child
+        }
+    }
+}
+
+type routeLearner struct {
+    Threshold int
+
+    mx   sync.Mutex
+    root *routeNode
+}
+
+func newRouteLearner(threshold int) *routeLearner {
+    return &routeLearner{
+        Threshold: threshold,
+        root:      newRouteNode(),
+    }
+}
+
+// Record the given path segments, collapsing positions that exceed the threshold, and rewrite
+// the segments in place to match the learned template.
+//
+func (self *routeLearner) Observe(segments []string) {
+    self.mx.Lock()
+    defer self.mx.Unlock()
+
+    node := self.root
+
+    for i, segment := range segments {
+        if node.collapsed {
+            segment = LEARNED_PLACEHOLDER
+        }
+
+        child, ok := node.children[segment]
+
+        if !ok {
+            child = newRouteNode()
+            node.children[segment] = child
+
+            if len(node.children) > self.Threshold {
+                merged := newRouteNode()
+
+                for _, existing := range node.children {
+                    merged.absorb(existing)
+                }
+
+                node.children = map[string]*routeNode{
+                    LEARNED_PLACEHOLDER: merged,
+                }
+
+                node.collapsed = true
+                segment = LEARNED_PLACEHOLDER
+                child = merged
+            }
+        }
+
+        segments[i] = segment
+        node = child
+    }
+}
+
+// Rewrite the given path segments in place to match the template learned so far, without
+// recording them.
+//
+func (self *routeLearner) Lookup(segments []string) {
+    self.mx.Lock()
+    defer self.mx.Unlock()
+
+    node := self.root
+
+    for i := range segments {
+        if node.collapsed {
+            segments[i] = LEARNED_PLACEHOLDER
+        }
+
+        child, ok := node.children[segments[i]]
+
+        if !ok {
+            return
+        }
+
+        node = child
+    }
+}
+
+func (self *routeLearner) Routes() []string {
+    self.mx.Lock()
+    defer self.mx.Unlock()
+
+    routes := make([]string, 0)
+
+    var walk func(*routeNode, []string)
+
+    walk = func(node *routeNode, prefix []string) {
+        if len(node.children) == 0 {
+            routes = append(routes, strings.Join(prefix, `/`))
+            return
+        }
+
+        for segment, child := range node.children {
+            walk(child, append(append([]string{}, prefix...), segment))
+        }
+    }
+
+    walk(self.root, []string{})
+    sort.Strings(routes)
+
+    return routes
+}
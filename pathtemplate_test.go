@@ -0,0 +1,77 @@
+package main
+
+import (
+    "fmt"
+    "reflect"
+    "testing"
+)
+
+func TestPathNormalizerDefaults(t *testing.T) {
+    normalizer := NewPathNormalizer()
+
+    expected := map[string]string{
+        `/api/27838`:                                   `/api/:id`,
+        `/api/4710?page=2`:                             `/api/:id`,
+        `/img/cache-9135.jpg`:                          `/img/cache-:id.jpg`,
+        `/users/6ba7b810-9dad-11d1-80b4-00c04fd430c8/`: `/users/:uuid/`,
+        `/blobs/d41d8cd98f00b204e9800998ecf8427e`:      `/blobs/:hash`,
+        `/api/v1/help`:                                 `/api/v1/help`,
+    }
+
+    for path, route := range expected {
+        if v := normalizer.Template(path); v != route {
+            t.Errorf("Expected '%s' to normalize to '%s', got '%s'", path, route, v)
+        }
+    }
+}
+
+func TestPathNormalizerPatterns(t *testing.T) {
+    normalizer := NewPathNormalizer()
+
+    if err := normalizer.AddPattern(`:sku=[A-Z]{3}-\d+`); err != nil {
+        t.Fatalf("Failed to add pattern: %v", err)
+    }
+
+    if v := normalizer.Template(`/products/ABC-12/reviews`); v != `/products/:sku/reviews` {
+        t.Errorf("Expected '/products/:sku/reviews', got '%s'", v)
+    }
+
+    for _, spec := range []string{ `nope`, `:x=(` } {
+        if err := normalizer.AddPattern(spec); err == nil {
+            t.Errorf("Expected pattern '%s' to be rejected", spec)
+        }
+    }
+}
+
+func TestPathNormalizerLearning(t *testing.T) {
+    normalizer := NewPathNormalizer()
+
+    if normalizer.Learning() {
+        t.Errorf("Expected routes not to be learned unless enabled")
+    }
+
+    normalizer.LearnRoutes(3)
+
+    for _, name := range []string{ `alice`, `bob`, `carol`, `dave`, `erin` } {
+        normalizer.Observe(fmt.Sprintf("/profiles/%s/settings", name))
+    }
+
+    if v := normalizer.Template(`/profiles/frank/settings`); v != `/profiles/:param/settings` {
+        t.Errorf("Expected learned route '/profiles/:param/settings', got '%s'", v)
+    }
+
+    if v := normalizer.Observe(`/help`); v != `/help` {
+        t.Errorf("Expected '/help' to be left alone, got '%s'", v)
+    }
+
+//  looking up routes does not count towards learning them
+    for _, name := range []string{ `a`, `b`, `c`, `d`, `e` } {
+        if v := normalizer.Template(`/files/` + name); v != `/files/` + name {
+            t.Errorf("Expected '/files/%s' to be left alone, got '%s'", name, v)
+        }
+    }
+
+    if routes := normalizer.LearnedRoutes(); !reflect.DeepEqual(routes, []string{ `/help`, `/profiles/:param/settings` }) {
+        t.Errorf("Unexpected learned routes: %+v", routes)
+    }
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+    "net/url"
+    "sort"
+    "strings"
+)
+
+const REDACTED_VALUE       = `REDACTED`
+const DEFAULT_QUERY_PARAMS = 10
+
+// Query string parameters whose values are redacted when redaction of common sensitive
+// parameters is enabled (see --redact), since they almost always carry credentials.  Names are
+// compared case-insensitively.
+//
+var SENSITIVE_PARAMS = []string{
+    `password`, `passwd`,
+    `token`, `access_token`, `refresh_token`, `id_token`,
+    `api_key`, `apikey`, `secret`, `client_secret`,
+}
+
+// The parameters (lowercase) whose values are replaced with REDACTED_VALUE wherever a query
+// string would be output (see RedactValue).  Empty (so nothing is redacted) unless redaction
+// is enabled.
+//
+var RedactedParams = map[string]bool{}
+
+func AddRedactedParams(names ...string) {
+    for _, name := range names {
+        if name = strings.ToLower(strings.TrimSpace(name)); name != `` {
+            RedactedParams[name] = true
+        }
+    }
+}
+
+// Redact the values of any RedactedParams in a value about to be output, which may be a URL
+// (or request path) or just a query string (e.g.: from an "args" log field).
+//
+func RedactValue(value string) string {
+    if len(RedactedParams) == 0 || !strings.Contains(value, `=`) {
+        return value
+    }
+
+    if strings.Contains(value, `?`) {
+        return RedactQuery(value)
+    }
+
+    return strings.TrimPrefix(RedactQuery(`?` + value), `?`)
+}
+
+// Return the parameters of the query string in the given request path (or URL).  Malformed
+// parameters are skipped.
+//
+func QueryParams(path string) url.Values {
+    parts := strings.SplitN(strings.SplitN(path, `#`, 2)[0], `?`, 2)
+
+    if len(parts) < 2 || parts[1] == `` {
+        return url.Values{}
+    }
+
+    values, _ := url.ParseQuery(parts[1])
+    return values
+}
+
+// Replace the values of any RedactedParams in the query string of the given request path (or
+// URL), leaving everything else (including the order of parameters) untouched.
+//
+func RedactQuery(path string) string {
+    queryAt := strings.Index(path, `?`)
+
+    if queryAt < 0 || len(RedactedParams) == 0 {
+        return path
+    }
+
+    query := path[queryAt + 1:]
+    fragment := ``
+
+    if fragmentAt := strings.Index(query, `#`); fragmentAt >= 0 {
+        fragment = query[fragmentAt:]
+        query = query[:fragmentAt]
+    }
+
+    pairs := strings.Split(query, `&`)
+    redacted := false
+
+    for i, pair := range pairs {
+        name := strings.SplitN(pair, `=`, 2)[0]
+
+        if unescaped, err := url.QueryUnescape(name); err == nil {
+            name = unescaped
+        }
+
+        if RedactedParams[strings.ToLower(name)] && strings.Contains(pair, `=`) {
+            pairs[i] = strings.SplitN(pair, `=`, 2)[0] + `=` + REDACTED_VALUE
+            redacted = true
+        }
+    }
+
+    if !redacted {
+        return path
+    }
+
+    return path[:queryAt + 1] + strings.Join(pairs, `&`) + fragment
+}
+
+// Tracks how often each query string parameter is used, how many distinct values each has,
+// and the most common values of selected parameters.
+//
+type QueryReport struct {
+    Requests    uint64
+    Names       *SpaceSaving
+    Cardinality map[string]*HyperLogLog
+    Values      map[string]*SpaceSaving
+
+    capacity    int
+}
+
+// Create a report tracking the top values of the given parameters, with each sketch (and the
+// number of parameter names whose cardinality is tracked) bounded by the given capacity.
+//
+func NewQueryReport(valueParams []string, capacity int) *QueryReport {
+    report := &QueryReport{
+        Names:       NewSpaceSaving(capacity),
+        Cardinality: make(map[string]*HyperLogLog),
+        Values:      make(map[string]*SpaceSaving),
+        capacity:    capacity,
+    }
+
+    for _, name := range valueParams {
+        report.Values[name] = NewSpaceSaving(capacity)
+    }
+
+    return report
+}
+
+func (self *QueryReport) Add(logLine *NcsaLog) {
+    self.Requests += 1
+
+    for name, values := range QueryParams(logLine.Path) {
+        self.Names.Add(name)
+
+        cardinality, ok := self.Cardinality[name]
+
+    //  only track the cardinality of a bounded number of distinct parameter names
+        if !ok && len(self.Cardinality) < self.capacity {
+            cardinality = NewHyperLogLog()
+            self.Cardinality[name] = cardinality
+        }
+
+        for _, value := range values {
+            if cardinality != nil {
+                cardinality.Add(value)
+            }
+
+            if sketch, ok := self.Values[name]; ok {
+                sketch.Add(value)
+            }
+        }
+    }
+}
+
+func (self *QueryReport) Merge(other *QueryReport) {
+    if other == nil {
+        return
+    }
+
+    self.Requests += other.Requests
+    self.Names.Merge(other.Names)
+
+    for name, cardinality := range other.Cardinality {
+        if existing, ok := self.Cardinality[name]; ok {
+            existing.Merge(cardinality)
+        }else if len(self.Cardinality) < self.capacity {
+            self.Cardinality[name] = NewHyperLogLog()
+            self.Cardinality[name].Merge(cardinality)
+        }
+    }
+
+    for name, sketch := range other.Values {
+        if existing, ok := self.Values[name]; ok {
+            existing.Merge(sketch)
+        }
+    }
+}
+
+// Return the names of the parameters whose values are tracked, sorted.
+//
+func (self *QueryReport) ValueParams() []string {
+    names := make([]string, 0, len(self.Values))
+
+    for name, _ := range self.Values {
+        names = append(names, name)
+    }
+
+    sort.Strings(names)
+    return names
+}
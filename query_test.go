@@ -0,0 +1,120 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestRedactQuery(t *testing.T) {
+    redacted := RedactedParams
+    RedactedParams = map[string]bool{}
+    defer func(){ RedactedParams = redacted }()
+
+    if v := RedactValue(`/reset?token=abc123`); v != `/reset?token=abc123` {
+        t.Errorf("Expected nothing to be redacted unless enabled, got '%s'", v)
+    }
+
+    AddRedactedParams(SENSITIVE_PARAMS...)
+    AddRedactedParams(`Code`)
+
+    for path, redacted := range map[string]string{
+        `/login`:                                `/login`,
+        `/search?q=shoes&page=2`:                `/search?q=shoes&page=2`,
+        `/reset?token=abc123&next=/home`:        `/reset?token=REDACTED&next=/home`,
+        `/cb?code=xyz&state=1#frag`:             `/cb?code=REDACTED&state=1#frag`,
+        `https://example.com/?api%5Fkey=s3cr3t`: `https://example.com/?api%5Fkey=REDACTED`,
+        `/flag?password`:                       `/flag?password`,
+    }{
+        if v := RedactQuery(path); v != redacted {
+            t.Errorf("Expected '%s' to be redacted as '%s', got '%s'", path, redacted, v)
+        }
+    }
+
+    for value, redacted := range map[string]string{
+        `password=hunter2&x=1`: `password=REDACTED&x=1`,
+        `/a?token=abc`:         `/a?token=REDACTED`,
+        `GET`:                  `GET`,
+    }{
+        if v := RedactValue(value); v != redacted {
+            t.Errorf("Expected '%s' to be redacted as '%s', got '%s'", value, redacted, v)
+        }
+    }
+
+//  the parsed line is left alone, while grouping keys are redacted
+    logLine := NcsaLog{}
+
+    if err := logLine.Parse(`10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /a?token=42 HTTP/1.1" 200 100 "-" "-" args="token=42&x=1"`); err != nil {
+        t.Fatalf("Failed to parse log line: %v", err)
+    }
+
+    if logLine.Path != `/a?token=42` || logLine.Fields[`args`] != `token=42&x=1` {
+        t.Errorf("Expected the parsed line to be left alone, got '%s' and '%s'", logLine.Path, logLine.Fields[`args`])
+    }
+
+    for expression, expected := range map[string]string{
+        `field:args`:   `token=REDACTED&x=1`,
+        `regex:\?(.*)`: `token=REDACTED`,
+        `path`:         `/a`,
+    }{
+        grouping, _ := ParseGrouping(expression)
+
+        if v, _ := grouping.Key(&logLine); v != expected {
+            t.Errorf("Expected '%s' grouping key '%s', got '%s'", expression, expected, v)
+        }
+    }
+}
+
+func TestQueryReport(t *testing.T) {
+    first := NewQueryReport([]string{ `utm_source`, `unused` }, DEFAULT_TOPK_CAPACITY)
+    second := NewQueryReport([]string{ `utm_source`, `unused` }, DEFAULT_TOPK_CAPACITY)
+
+    for i, path := range []string{
+        `/?utm_source=google&page=1`,
+        `/?utm_source=google&page=2&page=3`,
+        `/?utm_source=newsletter`,
+        `/?page=4`,
+        `/`,
+    }{
+        logLine := &NcsaLog{ Path: path }
+
+        if i % 2 == 0 {
+            first.Add(logLine)
+        }else{
+            second.Add(logLine)
+        }
+    }
+
+    first.Merge(second)
+
+    records := NewQueryParamRecords(first, 1)
+
+    if len(records) != 2 {
+        t.Fatalf("Expected the top parameter plus the tracked one, got %+v", records)
+    }
+
+    if records[0].Name != `page` || records[0].Count != 3 || records[0].DistinctValues != 4 || records[0].Share != 0.6 || len(records[0].TopValues) != 0 {
+        t.Errorf("Unexpected record for 'page': %+v", records[0])
+    }
+
+    if records[1].Name != `utm_source` || records[1].Count != 3 || records[1].DistinctValues != 2 {
+        t.Errorf("Unexpected record for 'utm_source': %+v", records[1])
+    }
+
+    if values := records[1].TopValues; len(values) != 1 || values[0].Value != `google` || values[0].Count != 2 {
+        t.Errorf("Expected 'google' to be the top utm_source, got %+v", values)
+    }
+}
+
+func TestQueryReportRedacted(t *testing.T) {
+    redacted := RedactedParams
+    RedactedParams = map[string]bool{}
+    defer func(){ RedactedParams = redacted }()
+
+    AddRedactedParams(`token`)
+
+    report := NewQueryReport([]string{ `token` }, DEFAULT_TOPK_CAPACITY)
+    report.Add(&NcsaLog{ Path: `/?token=abc` })
+
+    if records := NewQueryParamRecords(report, 1); len(records) != 1 || records[0].DistinctValues != 1 || len(records[0].TopValues) != 0 {
+        t.Errorf("Expected a redacted parameter to be counted without showing its values, got %+v", records)
+    }
+}
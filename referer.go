@@ -0,0 +1,109 @@
+package main
+
+import (
+    "net/url"
+    "strings"
+)
+
+// Separates the referer and path of a broken link within a heavy hitters key.
+//
+const BROKEN_LINK_SEPARATOR = "\x00"
+
+const REFERER_INTERNAL = `internal`
+const REFERER_EXTERNAL = `external`
+const REFERER_DIRECT   = `direct`
+
+// Domains (and their subdomains) considered part of this site, so that links between its own
+// pages can be told apart from inbound links.  The virtual host of each request (if logged) is
+// always considered internal.
+//
+var InternalDomains []string
+
+// Tracks where requests were referred from: the top internal and external referring domains,
+// and the referer and path of inbound links that resulted in a 404 (Not Found).
+//
+type RefererReport struct {
+    Direct      uint64
+    Internal    *SpaceSaving
+    External    *SpaceSaving
+    BrokenLinks *SpaceSaving
+}
+
+func NewRefererReport(capacity int) *RefererReport {
+    return &RefererReport{
+        Internal:    NewSpaceSaving(capacity),
+        External:    NewSpaceSaving(capacity),
+        BrokenLinks: NewSpaceSaving(capacity),
+    }
+}
+
+func (self *RefererReport) Add(logLine *NcsaLog) {
+    domain, ok := RefererDomain(logLine.Referer)
+
+    if !ok {
+        self.Direct += 1
+        return
+    }
+
+    if IsInternalReferer(domain, logLine) {
+        self.Internal.Add(domain)
+    }else{
+        self.External.Add(domain)
+    }
+
+    if logLine.StatusCode == 404 {
+        self.BrokenLinks.Add(strings.SplitN(logLine.Referer, `#`, 2)[0] + BROKEN_LINK_SEPARATOR + RequestPath(logLine.Path))
+    }
+}
+
+func (self *RefererReport) Merge(other *RefererReport) {
+    if other == nil {
+        return
+    }
+
+    self.Direct += other.Direct
+    self.Internal.Merge(other.Internal)
+    self.External.Merge(other.External)
+    self.BrokenLinks.Merge(other.BrokenLinks)
+}
+
+// Return the total number of requests seen, with or without a referer.
+//
+func (self *RefererReport) Total() uint64 {
+    return self.Direct + self.Internal.Total + self.External.Total
+}
+
+// Return the (lowercase) domain name of a referer URL, without any port.
+//
+func RefererDomain(referer string) (string, bool) {
+    if referer == `` || referer == `-` {
+        return ``, false
+    }
+
+    if u, err := url.Parse(referer); err == nil && u.Hostname() != `` {
+        return strings.TrimSuffix(strings.ToLower(u.Hostname()), `.`), true
+    }
+
+    return ``, false
+}
+
+// Return whether the given referring domain is part of this site: either one of the
+// InternalDomains (or a subdomain of one), or the virtual host the request was made to.
+//
+func IsInternalReferer(domain string, logLine *NcsaLog) bool {
+    for _, field := range VHOST_FIELDS {
+        if vhost, ok := logLine.Fields[field]; ok && strings.EqualFold(strings.SplitN(vhost, `:`, 2)[0], domain) {
+            return true
+        }
+    }
+
+    for _, internal := range InternalDomains {
+        internal = strings.ToLower(strings.TrimPrefix(internal, `.`))
+
+        if domain == internal || strings.HasSuffix(domain, `.` + internal) {
+            return true
+        }
+    }
+
+    return false
+}
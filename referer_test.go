@@ -0,0 +1,76 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestRefererDomain(t *testing.T) {
+    for referer, domain := range map[string]string{
+        `https://WWW.Example.com:8443/page?q=1`: `www.example.com`,
+        `http://example.org./`:                  `example.org`,
+        `android-app://com.example.app/`:        `com.example.app`,
+        `-`:                                     ``,
+        `not a url`:                             ``,
+    }{
+        if v, ok := RefererDomain(referer); v != domain || ok != (domain != ``) {
+            t.Errorf("Expected domain of '%s' to be '%s', got '%s'", referer, domain, v)
+        }
+    }
+}
+
+func TestRefererReport(t *testing.T) {
+    InternalDomains = []string{ `example.com` }
+    defer func(){ InternalDomains = nil }()
+
+    first := NewRefererReport(DEFAULT_TOPK_CAPACITY)
+    second := NewRefererReport(DEFAULT_TOPK_CAPACITY)
+
+    for i, line := range []string{
+        `10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /a HTTP/1.1" 200 100 "https://www.example.com/" "-"`,
+        `10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /b HTTP/1.1" 200 100 "https://shop.example.net/" "-" vhost=shop.example.net`,
+        `10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /old HTTP/1.1" 404 100 "https://blog.example.org/post#comments" "-"`,
+        `10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /old?utm=1 HTTP/1.1" 404 100 "https://blog.example.org/post" "-"`,
+        `10.0.0.1 - - [15/Mar/2016:22:58:38 -0400] "GET /missing HTTP/1.1" 404 100 "-" "-"`,
+    }{
+        logLine := NcsaLog{}
+
+        if err := logLine.Parse(line); err != nil {
+            t.Fatalf("Failed to parse log line: %v", err)
+        }
+
+        if i % 2 == 0 {
+            first.Add(&logLine)
+        }else{
+            second.Add(&logLine)
+        }
+    }
+
+    first.Merge(second)
+
+    if first.Total() != 5 || first.Direct != 1 {
+        t.Errorf("Expected 5 requests (1 direct), got %d (%d direct)", first.Total(), first.Direct)
+    }
+
+    expected := []RefererRecord{
+        { Domain: `blog.example.org`, Type: REFERER_EXTERNAL, Count: 2, Share: 0.4 },
+        { Domain: `-`,                Type: REFERER_DIRECT,   Count: 1, Share: 0.2 },
+        { Domain: `shop.example.net`, Type: REFERER_INTERNAL, Count: 1, Share: 0.2 },
+        { Domain: `www.example.com`,  Type: REFERER_INTERNAL, Count: 1, Share: 0.2 },
+    }
+
+    if records := NewRefererRecords(first, 10); len(records) != len(expected) {
+        t.Errorf("Expected %d referers, got %v", len(expected), records)
+    }else{
+        for i, record := range records {
+            if record != expected[i] {
+                t.Errorf("Expected referer %d to be %+v, got %+v", i, expected[i], record)
+            }
+        }
+    }
+
+    if records := NewBrokenLinkRecords(first, 10); len(records) != 1 {
+        t.Errorf("Expected 1 broken link, got %v", records)
+    }else if records[0].Referer != `https://blog.example.org/post` || records[0].Path != `/old` || records[0].Count != 2 {
+        t.Errorf("Unexpected broken link %+v", records[0])
+    }
+}
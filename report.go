@@ -0,0 +1,855 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/codegangsta/cli"
+    log "github.com/Sirupsen/logrus"
+)
+
+// Choose which sections from the given set should be displayed, based on the --top and
+// --with-section options.
+//
+func SelectSections(c *cli.Context, stats StatisticSet) []*LogStatistic {
+    sections := make([]*LogStatistic, 0)
+
+    for _, stat := range stats {
+        if onlySections := c.StringSlice(`with-section`); c.Int(`top`) <= 0 && len(onlySections) > 0 {
+            for _, name := range onlySections {
+                if stat.Key == name {
+                    sections = append(sections, stat)
+                    break
+                }
+            }
+
+        }else{
+            sections = append(sections, stat)
+        }
+    }
+
+//  if we're in "top" mode, only summarize the busiest sections
+    if n := c.Int(`top`); n > 0 {
+        sort.Slice(sections, func(i, j int) bool {
+            if sections[i].Count == sections[j].Count {
+                return sections[i].Key < sections[j].Key
+            }
+
+            return sections[i].Count > sections[j].Count
+        })
+
+        if len(sections) > n {
+            sections = sections[:n]
+        }
+    }
+
+    return sections
+}
+
+var SUMMARY_QUANTILES = []float64{ 0.5, 0.9, 0.99, 1 }
+
+const STATUS_DISPLAY_FAMILIES = `families`
+const STATUS_DISPLAY_CODES    = `codes`
+const STATUS_DISPLAY_BOTH     = `both`
+
+// Whether responses are broken down by status family, individual status code, or both.
+//
+var StatusDisplay = STATUS_DISPLAY_FAMILIES
+
+// Whether to include a breakdown of request methods and protocols in section summaries.
+//
+var ShowMethods = false
+
+// Whether to include a breakdown of cache statuses (and the cache hit ratio) in section
+// summaries, for log formats that record the cache status of each response.
+//
+var ShowCache = false
+
+// Whether to show how each section's request count and error ratio have changed since the
+// previous interval and the same interval a day and a week before, and the fractional change
+// in requests (or in error ratio) considered significant enough to highlight.
+//
+var ShowTrends          = false
+var TrendThreshold      = DEFAULT_TREND_THRESHOLD
+var TrendErrorThreshold = DEFAULT_TREND_ERROR_THRESHOLD
+
+// How many of the most common countries and networks to show in section summaries when a
+// GeoIP database is in use.
+//
+const GEO_TOP_COUNT = 3
+
+func PrintSectionHeader(label string) {
+    fmt.Printf("%s \tcount \tresponses \t", label)
+
+    if ShowCache {
+        fmt.Printf("cache (hit ratio) \t")
+    }
+
+    fmt.Printf("errors \tsize (p50/p90/p99/max) \ttime (p50/p90/p99/max) \tunique (hosts/clients/paths/sections) \tbandwidth (rate, share) \t")
+
+    if ShowMethods {
+        fmt.Printf("methods \tprotocols \t")
+    }
+
+    if GeoIP != nil {
+        fmt.Printf("countries \tnetworks \t")
+    }
+
+    if ShowTrends {
+        for _, comparison := range TREND_COMPARISONS {
+            fmt.Printf("vs %s \t", comparison.Label)
+        }
+    }
+
+    fmt.Printf("\n")
+}
+
+// Print one line per section, showing its hit count and status family breakdown (and, if
+// ShowTrends is set, its trends as given for the section at the same index).
+//
+func PrintSectionSummary(sections []*LogStatistic, summary *Bucket, trends []map[string]TrendRecord) {
+    for i, section := range sections {
+        if section != nil {
+            fmt.Printf("%s \t%d \t", section.Key, section.Count)
+
+            for _, fam := range statusBreakdown(section) {
+                fmt.Printf("%s ", colorizeStatus(fam))
+            }
+
+            if ShowCache {
+                fmt.Printf("\t%s ", formatCache(section))
+            }
+
+            fmt.Printf("\t%s \t%s \t%s \t%s \t%s \t", colorizeErrorRatio(section.ErrorRatio()), formatSizeQuantiles(section.Sizes), formatDurationQuantiles(section.Durations), formatUniques(section), formatBandwidth(section, summary))
+
+            if ShowMethods {
+                fmt.Printf("%s \t%s \t", formatCounts(section.Methods, ` `), formatCounts(section.Protocols, ` `))
+            }
+
+            if GeoIP != nil {
+                fmt.Printf("%s \t%s \t", formatTopCounts(section.Countries, GEO_TOP_COUNT, ` `), formatTopCounts(section.ASNs, GEO_TOP_COUNT, ` `))
+            }
+
+            if ShowTrends {
+                for _, comparison := range TREND_COMPARISONS {
+                    if i < len(trends) {
+                        if trend, ok := trends[i][comparison.Name]; ok {
+                            fmt.Printf("%s \t", formatTrend(trend))
+                            continue
+                        }
+                    }
+
+                    fmt.Printf("- \t")
+                }
+            }
+
+            fmt.Printf("\n")
+        }else{
+            fmt.Printf("%s \t%d\n", `-`, 0)
+        }
+    }
+}
+
+func PrintMultiWindowHeader(label string, windows []time.Duration) {
+    fmt.Printf("%s \t", label)
+
+    labels := make([]string, len(windows))
+
+    for i, w := range windows {
+        labels[i] = formatWindow(w)
+        fmt.Printf("%s \t", labels[i])
+    }
+
+    fmt.Printf("rate/s (%s) \tsize %s (p50/p90/p99/max) \ttime %s (p50/p90/p99/max) \tunique %s (hosts/clients/paths/sections) \tbandwidth %s (rate, share)\n", strings.Join(labels, `/`), labels[len(labels) - 1], labels[len(labels) - 1], labels[len(labels) - 1], labels[len(labels) - 1])
+}
+
+// Print one line per section showing, for each window, the hit count and error ratio side
+// by side, followed by the exponentially-weighted hit rates (similar to load averages) and
+// the response size and time quantiles, distinct counts and bandwidth over the largest window.
+//
+func PrintMultiWindowSummary(sections []*LogStatistic, spans []*Bucket, rates map[string][]float64) {
+    for _, section := range sections {
+        fmt.Printf("%s \t", section.Key)
+
+        for _, span := range spans {
+            if stat, ok := span.Sections[section.Key]; ok {
+                fmt.Printf("%d (%s) \t", stat.Count, colorizeErrorRatio(stat.ErrorRatio()))
+            }else{
+                fmt.Printf("0 (-) \t")
+            }
+        }
+
+        if values, ok := rates[section.Key]; ok {
+            formatted := make([]string, len(values))
+
+            for i, v := range values {
+                formatted[i] = fmt.Sprintf("%.2f", v)
+            }
+
+            fmt.Printf("%s", strings.Join(formatted, `/`))
+        }else{
+            fmt.Printf("-")
+        }
+
+        fmt.Printf(" \t%s \t%s \t%s \t%s\n", formatSizeQuantiles(section.Sizes), formatDurationQuantiles(section.Durations), formatUniques(section), formatBandwidth(section, spans[len(spans) - 1]))
+    }
+}
+
+func PrintSectionTreeHeader() {
+    fmt.Printf("path \tcount \tshare \tresponses \n")
+}
+
+// Print the section tree, pruned to the given depth and minimum share of total hits, with
+// each level indented beneath its parent.
+//
+func PrintSectionTree(tree *SectionTree, depth int, minShare float64) {
+    total := tree.Root.Stat.Count
+
+    for _, row := range tree.Prune(depth, minShare) {
+        indent := strings.Repeat(`  `, row.Depth)
+
+        if row.Node == nil {
+            fmt.Printf("%s(other) \t%d \t%s \t\n", indent, row.Others, formatShare(row.Others, total))
+            continue
+        }
+
+        fmt.Printf("%s%s \t%d \t%s \t", indent, row.Node.Path, row.Node.Stat.Count, formatShare(row.Node.Stat.Count, total))
+
+        for _, fam := range statusBreakdown(row.Node.Stat) {
+            fmt.Printf("%s ", colorizeStatus(fam))
+        }
+
+        fmt.Printf("\n")
+    }
+}
+
+func PrintHeavyHittersHeader(label string) {
+    fmt.Printf("%s \tcount \terror \tshare \n", label)
+}
+
+// Print the top N keys from a heavy hitters sketch, with their approximate counts, error bounds
+// (the true count lies between count-error and count) and share of all requests.
+//
+func PrintHeavyHitters(sketch *SpaceSaving, n int) {
+    for _, hitter := range sketch.Top(n) {
+        fmt.Printf("%s \t%d \t±%d \t%s \n", hitter.Key, hitter.Count, hitter.Error, formatShare(hitter.Count, sketch.Total))
+    }
+}
+
+func colorizeStatus(fam string) string {
+    return colorizeFamily(fam, fam)
+}
+
+// Color the given text according to a status family.
+//
+func colorizeFamily(fam string, text string) string {
+    switch fam[0] {
+    case '1':
+    case '2':
+        text = green(text)
+    case '4':
+        text = yellow(text)
+    case '5':
+        text = red(text)
+    default:
+        text = blue(text)
+    }
+
+    return text
+}
+
+// Return the non-zero status families and/or codes (depending on StatusDisplay) of the given
+// statistic as sorted "family=count" strings.
+//
+func statusBreakdown(stat *LogStatistic) []string {
+    breakdown := make([]string, 0)
+
+    if StatusDisplay != STATUS_DISPLAY_CODES {
+        families := make([]string, 0)
+
+        for status, count := range stat.GroupByStatusFamily() {
+            if count > 0 {
+                families = append(families, fmt.Sprintf("%s=%d", status, count))
+            }
+        }
+
+        sort.Strings(families)
+        breakdown = append(breakdown, families...)
+    }
+
+    if StatusDisplay != STATUS_DISPLAY_FAMILIES {
+        codes := make([]uint, 0, len(stat.Statuses))
+
+        for code := range stat.Statuses {
+            codes = append(codes, code)
+        }
+
+        sort.Slice(codes, func(i, j int) bool {
+            return codes[i] < codes[j]
+        })
+
+        for _, code := range codes {
+            if count := stat.Statuses[code]; count > 0 {
+                breakdown = append(breakdown, fmt.Sprintf("%d=%d", code, count))
+            }
+        }
+    }
+
+    return breakdown
+}
+
+// Format a set of counts as sorted "key=count" pairs.
+//
+func formatCounts(counts map[string]uint64, separator string) string {
+    keys := make([]string, 0, len(counts))
+
+    for key, count := range counts {
+        if count > 0 {
+            keys = append(keys, key)
+        }
+    }
+
+    sort.Strings(keys)
+
+    for i, key := range keys {
+        name := key
+
+        if name == `` {
+            name = `-`
+        }
+
+        keys[i] = fmt.Sprintf("%s=%d", name, counts[key])
+    }
+
+    return strings.Join(keys, separator)
+}
+
+// Format the n largest counts (largest first), followed by the number of others if any were
+// left out (e.g.: "US=40 DE=12 +3").
+//
+func formatTopCounts(counts map[string]uint64, n int, separator string) string {
+    keys := make([]string, 0, len(counts))
+
+    for key, count := range counts {
+        if count > 0 {
+            keys = append(keys, key)
+        }
+    }
+
+    if len(keys) == 0 {
+        return `-`
+    }
+
+    sort.Slice(keys, func(i, j int) bool {
+        if counts[keys[i]] == counts[keys[j]] {
+            return keys[i] < keys[j]
+        }
+
+        return counts[keys[i]] > counts[keys[j]]
+    })
+
+    formatted := make([]string, 0, n + 1)
+
+    for i, key := range keys {
+        if i == n {
+            formatted = append(formatted, fmt.Sprintf("+%d", len(keys) - n))
+            break
+        }
+
+        formatted = append(formatted, fmt.Sprintf("%s=%d", key, counts[key]))
+    }
+
+    return strings.Join(formatted, separator)
+}
+
+// Format the cache statuses of a section followed by its hit ratio (e.g.: "hit=40 miss=10 (80.0%)").
+//
+func formatCache(stat *LogStatistic) string {
+    if ratio, ok := stat.CacheHitRatio(); ok {
+        return fmt.Sprintf("%s (%.1f%%)", formatCounts(stat.Caches, ` `), ratio * 100)
+    }
+
+    return `-`
+}
+
+// Format a trend as an arrow and percentage change in requests (e.g.: "↑25%"), followed by the
+// change in error ratio if it is significant (e.g.: "↑25% err +2.1pp").  Changes beyond the
+// thresholds are highlighted: drops in traffic and rises in errors in red, and rises in traffic
+// in yellow.
+//
+func formatTrend(trend TrendRecord) string {
+    var formatted string
+
+    if trend.Change == nil {
+        formatted = `new`
+    }else{
+        change := *trend.Change
+
+        switch {
+        case change >= 0.005:
+            formatted = fmt.Sprintf("↑%.0f%%", change * 100)
+        case change <= -0.005:
+            formatted = fmt.Sprintf("↓%.0f%%", -change * 100)
+        default:
+            formatted = `→0%`
+        }
+
+        if change >= TrendThreshold {
+            formatted = yellow(formatted)
+        }else if change <= -TrendThreshold {
+            formatted = red(formatted)
+        }
+    }
+
+    if trend.ErrorRatioChange == nil {
+        return formatted
+    }
+
+    if change := *trend.ErrorRatioChange; change >= TrendErrorThreshold {
+        formatted += ` ` + red(fmt.Sprintf("err +%.1fpp", change * 100))
+    }else if change <= -TrendErrorThreshold {
+        formatted += ` ` + green(fmt.Sprintf("err %.1fpp", change * 100))
+    }
+
+    return formatted
+}
+
+func formatShare(count uint64, total uint64) string {
+    if total == 0 {
+        return `-`
+    }
+
+    return fmt.Sprintf("%.1f%%", float64(count) / float64(total) * 100)
+}
+
+func colorizeErrorRatio(ratio float64) string {
+    formatted := fmt.Sprintf("%.1f%%", ratio * 100)
+
+    if ratio >= 0.05 {
+        return red(formatted)
+    }else if ratio > 0 {
+        return yellow(formatted)
+    }
+
+    return green(formatted)
+}
+
+// Format a window duration compactly (e.g.: "1m" rather than "1m0s").
+//
+func formatWindow(w time.Duration) string {
+    label := w.String()
+
+    if strings.HasSuffix(label, `m0s`) {
+        label = strings.TrimSuffix(label, `0s`)
+    }
+
+    if strings.HasSuffix(label, `h0m`) {
+        label = strings.TrimSuffix(label, `0m`)
+    }
+
+    return label
+}
+
+// Print the clients that consumed the most bandwidth.
+//
+func PrintTopBandwidth(records []BandwidthRecord) {
+    fmt.Printf("client \tbytes \trate \tshare \n")
+
+    for _, record := range records {
+        fmt.Printf("%s \t%s (±%s) \t%s/s \t%.1f%% \n", record.Client, formatBytes(float64(record.Bytes)), formatBytes(float64(record.Error)), formatBytes(record.BytesPerSecond), record.Share * 100)
+    }
+}
+
+// Print the top referring domains, followed by the inbound links that most often led to a 404.
+//
+func PrintReferers(referers []RefererRecord, brokenLinks []BrokenLinkRecord) {
+    fmt.Printf("referer \ttype \tcount \tshare \n")
+
+    for _, record := range referers {
+        kind := record.Type
+
+        if kind == REFERER_EXTERNAL {
+            kind = blue(kind)
+        }
+
+        fmt.Printf("%s \t%s \t%d \t%.1f%% \n", record.Domain, kind, record.Count, record.Share * 100)
+    }
+
+    if len(brokenLinks) > 0 {
+        fmt.Printf("broken link \tcount \terror \n")
+
+        for _, record := range brokenLinks {
+            fmt.Printf("%s → %s \t%s \t±%d \n", record.Referer, record.Path, yellow(record.Count), record.Error)
+        }
+    }
+}
+
+// Print metrics over the sessions that ended in an interval, followed by the most common entry
+// and exit pages.
+//
+func PrintSessions(record *SessionRecord) {
+    fmt.Printf("sessions \tactive \tpages/session (p50/p90/p99/max) \tduration (p50/p90/p99/max) \tbounce rate \n")
+    fmt.Printf("%d \t%d \t%s (mean %.1f) \t%s \t%.1f%% \n",
+        record.Sessions, record.Active,
+        formatQuantiles(record.PageQuantiles, func(v float64) string { return fmt.Sprintf("%.0f", v) }), record.PagesPerSession,
+        formatQuantiles(record.DurationQuantiles, formatSeconds),
+        record.BounceRate * 100)
+
+    if len(record.EntryPages) > 0 {
+        fmt.Printf("entry page \tsessions \texit page \tsessions \n")
+
+        for i := 0; i < len(record.EntryPages) || i < len(record.ExitPages); i++ {
+            for _, pages := range [][]PageRecord{ record.EntryPages, record.ExitPages } {
+                if i < len(pages) {
+                    fmt.Printf("%s \t%d \t", pages[i].Path, pages[i].Count)
+                }else{
+                    fmt.Printf("- \t- \t")
+                }
+            }
+
+            fmt.Printf("\n")
+        }
+    }
+}
+
+// Print how many sessions reached each step of a funnel, and how many dropped off before it.
+//
+func PrintFunnel(steps []FunnelStepRecord) {
+    fmt.Printf("funnel step \tsessions \tconversion \tdrop-off \n")
+
+    for i, step := range steps {
+        if i == 0 {
+            fmt.Printf("%s \t%d \t%.1f%% \t- \n", step.Step, step.Sessions, step.Conversion * 100)
+        }else{
+            fmt.Printf("%s \t%d \t%.1f%% \t%s \n", step.Step, step.Sessions, step.Conversion * 100, yellow(step.DropOff))
+        }
+    }
+}
+
+// Print the most frequently used query string parameters, followed by the top values of any
+// parameters selected for value tracking.
+//
+func PrintQueryParams(params []QueryParamRecord) {
+    fmt.Printf("parameter \trequests \tshare \tdistinct values \n")
+
+    for _, param := range params {
+        fmt.Printf("%s \t%d \t%.1f%% \t%d \n", param.Name, param.Count, param.Share * 100, param.DistinctValues)
+    }
+
+    for _, param := range params {
+        if len(param.TopValues) > 0 {
+            fmt.Printf("%s \tcount \tshare \n", param.Name)
+
+            for _, value := range param.TopValues {
+                fmt.Printf("%s \t%d \t%s \n", value.Value, value.Count, formatShare(value.Count, param.Count))
+            }
+        }
+    }
+}
+
+// Print each matrix of a heatmap as a grid of days (rows) by hours (columns), with each cell
+// shaded according to its count relative to the busiest hour of that matrix.
+//
+func PrintHeatmap(heatmap *Heatmap) {
+    for _, key := range heatmap.Keys() {
+        matrix := heatmap.Matrices[key]
+        max := matrix.Max()
+
+        fmt.Printf("\n%s (%s, %d requests)\n    ", key, heatmap.Location, matrix.Total())
+
+        for hour := 0; hour < 24; hour++ {
+            fmt.Printf(" %4s", fmt.Sprintf("%02d", hour))
+        }
+
+        fmt.Printf("  total\n")
+
+        for i, weekday := range HEATMAP_WEEKDAYS {
+            var total uint64
+
+            fmt.Printf("%s ", weekday.String()[:3])
+
+            for _, count := range matrix[i] {
+                total += count
+                fmt.Printf(" %s", shadeHeatmapCell(count, max))
+            }
+
+            fmt.Printf("  %d\n", total)
+        }
+    }
+}
+
+func shadeHeatmapCell(count uint64, max uint64) string {
+    if count == 0 {
+        return `   ·`
+    }
+
+    shade := int(float64(count) / float64(max) * float64(len(heatShades)))
+
+    if shade >= len(heatShades) {
+        shade = len(heatShades) - 1
+    }
+
+    return heatShades[shade](fmt.Sprintf("%4s", formatCount(count)))
+}
+
+// Format a count in at most four characters (e.g.: "950", "9.5k", "950k", "9.5M").
+//
+func formatCount(count uint64) string {
+    value := float64(count)
+
+    for _, unit := range []string{ ``, `k`, `M`, `G` } {
+        if unit == `` && value < 10000 {
+            return fmt.Sprintf("%.0f", value)
+        }else if unit != `` && value < 9.95 {
+            return fmt.Sprintf("%.1f%s", value, unit)
+        }else if unit != `` && value < 999.5 {
+            return fmt.Sprintf("%.0f%s", value, unit)
+        }
+
+        value = value / 1000
+    }
+
+    return fmt.Sprintf("%.0fT", value)
+}
+
+// The widest bar drawn in a timeline, in characters.
+//
+const TIMELINE_BAR_WIDTH = 50
+
+// How many of the busiest sections are given their own sparklines beneath a timeline.
+//
+const TIMELINE_SECTIONS = 10
+
+var SPARKLINE_LEVELS = []rune(`▁▂▃▄▅▆▇█`)
+
+// Print a timeline as one bar per bucket (colored by status family), followed by a sparkline
+// of each of the busiest sections and of its status families.
+//
+func PrintTimeline(timeline *Timeline) {
+    times := timeline.Times()
+    families := csvStatusColumns()
+    layout := timelineLayout(timeline.Width)
+
+    var max uint64
+
+    for _, start := range times {
+        if total, _ := timeline.Counts(start).Totals(); total > max {
+            max = total
+        }
+    }
+
+    fmt.Printf("\ntime (%s buckets) \tcount \tresponses \t\n", formatWindow(timeline.Width))
+
+    for _, start := range times {
+        total, totals := timeline.Counts(start).Totals()
+        breakdown := make([]string, 0)
+        bar := ``
+        drawn := 0
+        cumulative := uint64(0)
+
+    //  each family's segment ends where its cumulative share of the bar does, so that rounding
+    //  never makes the bar longer or shorter than the total
+        for _, family := range families {
+            if count := totals[family]; count > 0 {
+                cumulative += count
+                width := int(cumulative * TIMELINE_BAR_WIDTH / max) - drawn
+                drawn += width
+
+                breakdown = append(breakdown, colorizeStatus(fmt.Sprintf("%s=%d", family, count)))
+                bar += colorizeFamily(family, strings.Repeat(`█`, width))
+            }
+        }
+
+        fmt.Printf("%s \t%d \t%s \t%s\n", start.Format(layout), total, strings.Join(breakdown, ` `), bar)
+    }
+
+    sections := timeline.Sections()
+
+    if len(sections) > TIMELINE_SECTIONS {
+        sections = sections[:TIMELINE_SECTIONS]
+    }
+
+    fmt.Printf("\nsection \tcount \ttimeline (%s to %s) \t\n", timeline.Earliest.Format(layout), timeline.Latest.Format(layout))
+
+    for _, section := range sections {
+        series := make([]uint64, len(times))
+        familySeries := make(map[string][]uint64)
+        var total uint64
+
+        for i, start := range times {
+            count, counts := timeline.Counts(start).Section(section)
+            series[i] = count
+            total += count
+
+            for family, count := range counts {
+                if _, ok := familySeries[family]; !ok {
+                    familySeries[family] = make([]uint64, len(times))
+                }
+
+                familySeries[family][i] = count
+            }
+        }
+
+        fmt.Printf("%s \t%d \t%s \t\n", section, total, sparkline(series))
+
+        for _, family := range families {
+            if values, ok := familySeries[family]; ok {
+                var familyTotal uint64
+
+                for _, count := range values {
+                    familyTotal += count
+                }
+
+                fmt.Printf("  %s \t%d \t%s \t\n", family, familyTotal, colorizeFamily(family, sparkline(values)))
+            }
+        }
+    }
+}
+
+// Draw a series of counts as a sparkline, scaled to its largest value (with empty buckets left
+// blank).
+//
+func sparkline(series []uint64) string {
+    var max uint64
+
+    for _, count := range series {
+        if count > max {
+            max = count
+        }
+    }
+
+    line := make([]rune, len(series))
+
+    for i, count := range series {
+        if count == 0 {
+            line[i] = ' '
+        }else{
+            line[i] = SPARKLINE_LEVELS[(count * uint64(len(SPARKLINE_LEVELS)) - 1) / max]
+        }
+    }
+
+    return string(line)
+}
+
+// Choose how precisely to show bucket times, given their width.
+//
+func timelineLayout(width time.Duration) string {
+    if width % (24 * time.Hour) == 0 {
+        return `2006-01-02`
+    }else if width % time.Minute == 0 {
+        return `2006-01-02 15:04`
+    }
+
+    return `2006-01-02 15:04:05`
+}
+
+// Log a one-line summary of the totals across all sections.
+//
+func LogTotals(summary *Bucket) {
+    totals := summary.Totals
+
+    log.Infof("Totals: %d hits, %s served (%s/s), %d unique hosts, %d unique clients, %d unique paths",
+        totals.Count, formatBytes(float64(totals.Bytes)), formatBytes(bytesPerSecond(totals.Bytes, summary.Elapsed())),
+        totals.Hosts.Count(), totals.Clients.Count(), totals.Paths.Count())
+}
+
+func formatBandwidth(stat *LogStatistic, summary *Bucket) string {
+    var share float64
+
+    if summary.Totals.Bytes > 0 {
+        share = float64(stat.Bytes) / float64(summary.Totals.Bytes)
+    }
+
+    return fmt.Sprintf("%s (%s/s, %.1f%%)", formatBytes(float64(stat.Bytes)), formatBytes(bytesPerSecond(stat.Bytes, summary.Elapsed())), share * 100)
+}
+
+func bytesPerSecond(bytes uint64, elapsed time.Duration) float64 {
+    if elapsed <= 0 {
+        return 0
+    }
+
+    return float64(bytes) / elapsed.Seconds()
+}
+
+func formatUniques(stat *LogStatistic) string {
+    return fmt.Sprintf("%d/%d/%d/%d", stat.Hosts.Count(), stat.Clients.Count(), stat.Paths.Count(), stat.Sections.Count())
+}
+
+func formatSizeQuantiles(sketch *QuantileSketch) string {
+    if sketch == nil || sketch.Count == 0 {
+        return `-`
+    }
+
+    values := make([]string, len(SUMMARY_QUANTILES))
+
+    for i, q := range SUMMARY_QUANTILES {
+        values[i] = formatBytes(sketch.Quantile(q))
+    }
+
+    return strings.Join(values, `/`)
+}
+
+func formatDurationQuantiles(sketch *QuantileSketch) string {
+    if sketch == nil || sketch.Count == 0 {
+        return `-`
+    }
+
+    values := make([]string, len(SUMMARY_QUANTILES))
+
+    for i, q := range SUMMARY_QUANTILES {
+        values[i] = formatSeconds(sketch.Quantile(q))
+    }
+
+    return strings.Join(values, `/`)
+}
+
+// Format the SUMMARY_QUANTILES from a map of quantile names to values.
+//
+func formatQuantiles(quantiles map[string]float64, format func(float64) string) string {
+    if len(quantiles) == 0 {
+        return `-`
+    }
+
+    values := make([]string, len(SUMMARY_QUANTILES))
+
+    for i, q := range SUMMARY_QUANTILES {
+        values[i] = format(quantiles[quantileName(q)])
+    }
+
+    return strings.Join(values, `/`)
+}
+
+// Format a number of bytes using binary (1024-based) units.
+//
+func formatBytes(bytes float64) string {
+    units := []string{ `B`, `KiB`, `MiB`, `GiB`, `TiB`, `PiB` }
+    unit := 0
+
+    for bytes >= 1024 && unit < len(units) - 1 {
+        bytes = bytes / 1024
+        unit += 1
+    }
+
+    if unit == 0 {
+        return fmt.Sprintf("%.0f%s", bytes, units[unit])
+    }
+
+    return fmt.Sprintf("%.1f%s", bytes, units[unit])
+}
+
+// Format a number of seconds as a duration, rounded to a sensible precision.
+//
+func formatSeconds(seconds float64) string {
+    duration := time.Duration(seconds * float64(time.Second))
+
+    switch {
+    case duration >= time.Second:
+        duration = duration.Round(time.Millisecond)
+    case duration >= time.Millisecond:
+        duration = duration.Round(time.Microsecond)
+    }
+
+    return duration.String()
+}
@@ -1,15 +1,27 @@
 package main
 
-type Ring struct {
+import (
+    "sort"
+    "sync"
+)
+
+// A fixed-size circular buffer of values.  Once full, each push overwrites the oldest value.
+// All methods are safe for concurrent use.
+//
+type Ring[T any] struct {
     Size int
-    Data []interface{}
+    Data []T
 
+    mx         sync.RWMutex
+    sequence   []uint64
     writeIdx   int
     writeCount int
+    nextSeq    uint64
+    seeked     bool
 }
 
-func NewRing(size int) *Ring {
-    ring := &Ring{
+func NewRing[T any](size int) *Ring[T] {
+    ring := &Ring[T]{
         Size: size,
     }
 
@@ -18,26 +30,231 @@ func NewRing(size int) *Ring {
     return ring
 }
 
-func (self *Ring) Clear() {
+func (self *Ring[T]) Clear() {
+    self.mx.Lock()
+    defer self.mx.Unlock()
+
     self.writeCount = 0
     self.writeIdx = 0
-    self.Data = make([]interface{}, self.Size)
+    self.nextSeq = 0
+    self.seeked = false
+    self.Data = make([]T, self.Size)
+    self.sequence = make([]uint64, self.Size)
+}
+
+func (self *Ring[T]) Length() int {
+    self.mx.RLock()
+    defer self.mx.RUnlock()
+
+    return self.length()
 }
 
-func (self *Ring) Length() int {
+func (self *Ring[T]) length() int {
     return len(self.Data)
 }
 
-func (self *Ring) WriteCount() int {
+func (self *Ring[T]) WriteCount() int {
+    self.mx.RLock()
+    defer self.mx.RUnlock()
+
     return self.writeCount
 }
 
-func (self *Ring) Push(datum interface{}) {
+func (self *Ring[T]) Push(datum T) {
+    self.mx.Lock()
+    defer self.mx.Unlock()
+
+    self.push(datum)
+}
+
+// Move the write position so that the next push overwrites the value at the given position.
+// Chronological order is tracked per value, so iteration remains in push order afterwards.
+//
+func (self *Ring[T]) Seek(pos int) {
+    self.mx.Lock()
+    defer self.mx.Unlock()
+
+    self.writeIdx = (pos % self.length())
+    self.seeked = true
+}
+
+// Return all values that have been written, oldest first.
+//
+func (self *Ring[T]) Values() []T {
+    self.mx.RLock()
+    defer self.mx.RUnlock()
+
+    return self.last(self.length())
+}
+
+// Return (up to) the n most recently pushed values, oldest first.
+//
+func (self *Ring[T]) Last(n int) []T {
+    self.mx.RLock()
+    defer self.mx.RUnlock()
+
+    return self.last(n)
+}
+
+// Call the given function for each value that has been written, oldest first.
+//
+func (self *Ring[T]) Each(fn func(int, T)) {
+    for i, value := range self.Values() {
+        fn(i, value)
+    }
+}
+
+// Return an independent copy of this ring that can be read without regard to concurrent
+// writes on the original.
+//
+func (self *Ring[T]) Snapshot() *Ring[T] {
+    self.mx.RLock()
+    defer self.mx.RUnlock()
+
+    snapshot := &Ring[T]{
+        Size:       self.Size,
+        Data:       make([]T, len(self.Data)),
+        sequence:   make([]uint64, len(self.sequence)),
+        writeIdx:   self.writeIdx,
+        writeCount: self.writeCount,
+        nextSeq:    self.nextSeq,
+        seeked:     self.seeked,
+    }
+
+    copy(snapshot.Data, self.Data)
+    copy(snapshot.sequence, self.sequence)
+
+    return snapshot
+}
+
+func (self *Ring[T]) push(datum T) {
+    self.nextSeq += 1
     self.Data[self.writeIdx] = datum
-    self.writeIdx = (self.writeIdx + 1) % self.Length()
+    self.sequence[self.writeIdx] = self.nextSeq
+    self.writeIdx = (self.writeIdx + 1) % self.length()
     self.writeCount += 1
 }
 
-func (self *Ring) Seek(pos int) {
-    self.writeIdx = (pos % self.Length())
+func (self *Ring[T]) last(n int) []T {
+    if n > self.writeCount {
+        n = self.writeCount
+    }
+
+    if n > self.length() {
+        n = self.length()
+    }
+
+    values := make([]T, 0, n)
+
+    if n <= 0 {
+        return values
+    }
+
+//  without seeking, the newest value always sits just before the write position
+    if !self.seeked {
+        for i := 0; i < n; i++ {
+            values = append(values, self.Data[(self.writeIdx - n + i + self.length()) % self.length()])
+        }
+
+        return values
+    }
+
+//  ...otherwise, order the written slots by the sequence in which they were pushed
+    slots := make([]int, 0, self.length())
+
+    for i, seq := range self.sequence {
+        if seq > 0 {
+            slots = append(slots, i)
+        }
+    }
+
+    sort.Slice(slots, func(i, j int) bool {
+        return self.sequence[slots[i]] < self.sequence[slots[j]]
+    })
+
+    if n > len(slots) {
+        n = len(slots)
+    }
+
+    for _, slot := range slots[len(slots) - n:] {
+        values = append(values, self.Data[slot])
+    }
+
+    return values
+}
+
+type Number interface {
+    ~int | ~int8 | ~int16 | ~int32 | ~int64 |
+    ~uint | ~uint8 | ~uint16 | ~uint32 | ~uint64 |
+    ~float32 | ~float64
+}
+
+// A ring of numeric values, with helpers for summarizing them.
+//
+type NumericRing[T Number] struct {
+    *Ring[T]
+}
+
+func NewNumericRing[T Number](size int) *NumericRing[T] {
+    return &NumericRing[T]{
+        Ring: NewRing[T](size),
+    }
+}
+
+// Return the sum of all written values.
+//
+func (self *NumericRing[T]) Sum() T {
+    var sum T
+
+    for _, v := range self.Values() {
+        sum += v
+    }
+
+    return sum
+}
+
+// Return the arithmetic mean of all written values (or zero if nothing has been written).
+//
+func (self *NumericRing[T]) Mean() float64 {
+    values := self.Values()
+
+    if len(values) == 0 {
+        return 0
+    }
+
+    var sum float64
+
+    for _, v := range values {
+        sum += float64(v)
+    }
+
+    return sum / float64(len(values))
+}
+
+// Return the smallest written value (or zero if nothing has been written).
+//
+func (self *NumericRing[T]) Min() T {
+    var min T
+
+    for i, v := range self.Values() {
+        if i == 0 || v < min {
+            min = v
+        }
+    }
+
+    return min
+}
+
+// Return the largest written value (or zero if nothing has been written).
+//
+func (self *NumericRing[T]) Max() T {
+    var max T
+
+    for i, v := range self.Values() {
+        if i == 0 || v > max {
+            max = v
+        }
+    }
+
+    return max
 }
@@ -1,11 +1,13 @@
 package main
 
 import (
+    "reflect"
+    "sync"
     "testing"
 )
 
 func TestCreateRing(t *testing.T) {
-    ring := NewRing(10)
+    ring := NewRing[int](10)
 
     if ring.Length() != 10 {
         t.Errorf("Failed: expected len=10, got len=%d", ring.Length())
@@ -13,16 +15,16 @@ func TestCreateRing(t *testing.T) {
 }
 
 func TestRingPushSeek(t *testing.T) {
-    ring := NewRing(4)
+    ring := NewRing[int](4)
 
     ring.Push(1)
     ring.Push(2)
     ring.Push(3)
     ring.Push(4)
 
-    shouldBe1 := []interface{}{ 1, 2, 3, 4 }
-    shouldBe2 := []interface{}{ 5, 6, 7, 8 }
-    shouldBe3 := []interface{}{ 5, 6, 7, 9 }
+    shouldBe1 := []int{ 1, 2, 3, 4 }
+    shouldBe2 := []int{ 5, 6, 7, 8 }
+    shouldBe3 := []int{ 5, 6, 7, 9 }
 
     for i, val := range ring.Data {
         if shouldBe1[i] != val {
@@ -53,7 +55,7 @@ func TestRingPushSeek(t *testing.T) {
 
 
 func TestRingClear(t *testing.T) {
-    ring := NewRing(4)
+    ring := NewRing[int](4)
 
     ring.Push(1)
     ring.Push(2)
@@ -61,8 +63,8 @@ func TestRingClear(t *testing.T) {
     ring.Push(4)
     ring.Push(5)
 
-    shouldBe1 := []interface{}{ 5, 2, 3, 4 }
-    shouldBe2 := []interface{}{ 6, 7, 8, nil }
+    shouldBe1 := []int{ 5, 2, 3, 4 }
+    shouldBe2 := []int{ 6, 7, 8, 0 }
 
     for i, val := range ring.Data {
         if shouldBe1[i] != val {
@@ -81,4 +83,122 @@ func TestRingClear(t *testing.T) {
             t.Errorf("Slice incorrect: should be %+v, got %+v", shouldBe2, ring.Data)
         }
     }
-}
\ No newline at end of file
+}
+
+func TestRingOrderedIteration(t *testing.T) {
+    ring := NewRing[int](4)
+
+    if values := ring.Values(); len(values) != 0 {
+        t.Errorf("Expected no values from an empty ring, got %+v", values)
+    }
+
+    ring.Push(1)
+    ring.Push(2)
+
+    if values := ring.Values(); !reflect.DeepEqual(values, []int{ 1, 2 }) {
+        t.Errorf("Expected partially-filled values [1 2], got %+v", values)
+    }
+
+    for i := 3; i <= 6; i++ {
+        ring.Push(i)
+    }
+
+    if values := ring.Values(); !reflect.DeepEqual(values, []int{ 3, 4, 5, 6 }) {
+        t.Errorf("Expected oldest-to-newest values [3 4 5 6], got %+v", values)
+    }
+
+    if values := ring.Last(2); !reflect.DeepEqual(values, []int{ 5, 6 }) {
+        t.Errorf("Expected last two values [5 6], got %+v", values)
+    }
+
+    visited := make([]int, 0)
+
+    ring.Each(func(i int, v int){
+        visited = append(visited, v)
+    })
+
+    if !reflect.DeepEqual(visited, []int{ 3, 4, 5, 6 }) {
+        t.Errorf("Expected Each to visit [3 4 5 6], got %+v", visited)
+    }
+}
+
+func TestRingSeekPreservesChronology(t *testing.T) {
+    ring := NewRing[int](4)
+
+    for i := 1; i <= 4; i++ {
+        ring.Push(i)
+    }
+
+    ring.Seek(1)
+    ring.Push(9)
+
+    if values := ring.Values(); !reflect.DeepEqual(values, []int{ 1, 3, 4, 9 }) {
+        t.Errorf("Expected values in push order [1 3 4 9], got %+v", values)
+    }
+
+    if values := ring.Last(1); !reflect.DeepEqual(values, []int{ 9 }) {
+        t.Errorf("Expected newest value [9], got %+v", values)
+    }
+}
+
+func TestRingSnapshot(t *testing.T) {
+    ring := NewRing[int](4)
+    ring.Push(1)
+    ring.Push(2)
+
+    snapshot := ring.Snapshot()
+    ring.Push(3)
+
+    if values := snapshot.Values(); !reflect.DeepEqual(values, []int{ 1, 2 }) {
+        t.Errorf("Snapshot should not see later writes, got %+v", values)
+    }
+
+    var wg sync.WaitGroup
+
+    for i := 0; i < 4; i++ {
+        wg.Add(1)
+
+        go func(){
+            defer wg.Done()
+
+            for j := 0; j < 100; j++ {
+                ring.Push(j)
+                ring.Snapshot().Values()
+            }
+        }()
+    }
+
+    wg.Wait()
+
+    if ring.WriteCount() != 403 {
+        t.Errorf("Expected 403 writes, got %d", ring.WriteCount())
+    }
+}
+
+func TestNumericRingHelpers(t *testing.T) {
+    ring := NewNumericRing[uint64](4)
+
+    if ring.Mean() != 0 || ring.Sum() != 0 || ring.Min() != 0 || ring.Max() != 0 {
+        t.Errorf("Expected zero-valued helpers on an empty ring")
+    }
+
+    for _, v := range []uint64{ 9, 2, 4, 6, 8 } {
+        ring.Push(v)
+    }
+
+    if ring.Sum() != 20 {
+        t.Errorf("Expected sum=20, got %d", ring.Sum())
+    }
+
+    if ring.Mean() != 5 {
+        t.Errorf("Expected mean=5, got %f", ring.Mean())
+    }
+
+    if ring.Min() != 2 {
+        t.Errorf("Expected min=2, got %d", ring.Min())
+    }
+
+    if ring.Max() != 8 {
+        t.Errorf("Expected max=8, got %d", ring.Max())
+    }
+}
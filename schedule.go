@@ -0,0 +1,103 @@
+package main
+
+import (
+    "fmt"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// A Scheduler emits one tick for every boundary of a fixed resolution, aligned to the
+// wall clock (e.g.: a resolution of 1m ticks exactly on the minute).  Boundaries are
+// computed from a fixed starting point rather than from the previous tick, so processing
+// time never causes the schedule to drift.  If a receiver falls behind, the ticks it missed
+// are delivered back-to-back once it catches up; no boundary is ever skipped or repeated.
+//
+type Scheduler struct {
+    Resolution time.Duration
+    C          <-chan time.Time
+
+    ticks chan time.Time
+    stop  chan bool
+}
+
+func NewScheduler(resolution time.Duration) *Scheduler {
+    scheduler := &Scheduler{
+        Resolution: resolution,
+        ticks:      make(chan time.Time),
+        stop:       make(chan bool),
+    }
+
+    scheduler.C = scheduler.ticks
+
+    go scheduler.run()
+
+    return scheduler
+}
+
+// Return the first boundary strictly after the given time.
+//
+func (self *Scheduler) NextBoundary(after time.Time) time.Time {
+    next := after.Truncate(self.Resolution).Add(self.Resolution)
+
+//  re-derive the boundary from the given time so that it retains its monotonic clock reading
+    return after.Add(next.Sub(after))
+}
+
+func (self *Scheduler) Stop() {
+    close(self.stop)
+}
+
+func (self *Scheduler) run() {
+    next := self.NextBoundary(time.Now())
+
+    for {
+        timer := time.NewTimer(time.Until(next))
+
+        select {
+        case <-timer.C:
+        case <-self.stop:
+            timer.Stop()
+            return
+        }
+
+        select {
+        case self.ticks <- next.Round(0):
+        case <-self.stop:
+            return
+        }
+
+        next = next.Add(self.Resolution)
+    }
+}
+
+// Returns whether the given tick falls on a boundary of the given interval.
+//
+func IsIntervalBoundary(tick time.Time, interval time.Duration) bool {
+    return tick.Truncate(interval).Equal(tick)
+}
+
+// Parse a duration string (e.g.: "500ms", "5m").  For backwards compatibility, bare
+// integers are interpreted as a number of seconds.
+//
+func ParseDuration(value string) (time.Duration, error) {
+    value = strings.TrimSpace(value)
+
+    if v, err := strconv.ParseInt(value, 10, 64); err == nil {
+        return time.Duration(v) * time.Second, nil
+    }
+
+    if duration, err := time.ParseDuration(value); err == nil {
+        return duration, nil
+    }else{
+        return 0, fmt.Errorf("Invalid duration '%s'", value)
+    }
+}
+
+// Implements sort.Interface for a list of durations (shortest first).
+//
+type durations []time.Duration
+
+func (self durations) Len() int           { return len(self) }
+func (self durations) Less(i, j int) bool { return self[i] < self[j] }
+func (self durations) Swap(i, j int)      { self[i], self[j] = self[j], self[i] }
@@ -0,0 +1,53 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestSchedulerAlignedTicks(t *testing.T) {
+    resolution := 20 * time.Millisecond
+    scheduler := NewScheduler(resolution)
+    defer scheduler.Stop()
+
+    var last time.Time
+
+    for i := 0; i < 5; i++ {
+        tick := <-scheduler.C
+
+        if !IsIntervalBoundary(tick, resolution) {
+            t.Errorf("Tick %v is not aligned to %v", tick, resolution)
+        }
+
+        if !last.IsZero() && tick.Sub(last) != resolution {
+            t.Errorf("Expected consecutive ticks %v apart, got %v", resolution, tick.Sub(last))
+        }
+
+    //  simulate a slow consumer; the missed ticks should still be delivered in sequence
+        if i == 1 {
+            time.Sleep(3 * resolution)
+        }
+
+        last = tick
+    }
+}
+
+func TestParseDuration(t *testing.T) {
+    expected := map[string]time.Duration{
+        `10`:    10 * time.Second,
+        `500ms`: 500 * time.Millisecond,
+        `5m`:    5 * time.Minute,
+    }
+
+    for value, duration := range expected {
+        if v, err := ParseDuration(value); err != nil {
+            t.Errorf("Failed to parse '%s': %v", value, err)
+        }else if v != duration {
+            t.Errorf("Expected '%s' to be %v, got %v", value, duration, v)
+        }
+    }
+
+    if _, err := ParseDuration(`soon`); err == nil {
+        t.Errorf("Expected an error parsing an invalid duration")
+    }
+}
@@ -0,0 +1,275 @@
+package main
+
+import (
+    "container/list"
+    "time"
+)
+
+const DEFAULT_SESSION_TIMEOUT = `30m`
+const DEFAULT_MAX_SESSIONS    = 100000
+
+// Content classes (see ContentClass) of requests for page assets (rather than pages
+// themselves), which are counted as hits within a session but not as page views.
+//
+var ASSET_CLASSES = map[string]bool{
+    `image`:  true,
+    `script`: true,
+    `style`:  true,
+    `font`:   true,
+    `media`:  true,
+}
+
+// Return whether the given request was for a page (as opposed to an asset such as a stylesheet,
+// script, image or font).  Only successful GET requests are considered page views.
+//
+func IsPageView(logLine *NcsaLog) bool {
+    if logLine.Method != `GET` || logLine.StatusCode < 200 || logLine.StatusCode >= 400 {
+        return false
+    }
+
+    return !ASSET_CLASSES[ContentClass(logLine.Path)]
+}
+
+// A visit to the site by a single client (identified by address and user agent), ending once
+// the client has been inactive for longer than the session timeout.
+//
+type Session struct {
+    Key       string    `json:"-"`
+    Client    string    `json:"client"`
+    UserAgent string    `json:"user_agent"`
+    Start     time.Time `json:"start"`
+    End       time.Time `json:"end"`
+    Hits      uint64    `json:"hits"`
+    Pages     uint64    `json:"pages"`
+    Bytes     uint64    `json:"bytes"`
+    EntryPage string    `json:"entry_page"`
+    ExitPage  string    `json:"exit_page"`
+    Funnel    int       `json:"funnel_steps,omitempty"`
+
+    element   *list.Element
+}
+
+func (self *Session) Duration() time.Duration {
+    return self.End.Sub(self.Start)
+}
+
+// A session with at most one page view.
+//
+func (self *Session) IsBounce() bool {
+    return self.Pages <= 1
+}
+
+// Reconstructs sessions from a stream of log lines.  Sessions are expired according to the
+// timestamps in the logs themselves (so that historical logs are sessionized the same way as
+// live ones), and memory is bounded by closing the least recently active session whenever
+// more than MaxSessions are open.  Each session is passed to the OnClose callback as it ends,
+// and each move from one page to the next within a session is passed to OnTransition (if set).
+//
+type SessionTracker struct {
+    Timeout      time.Duration
+    MaxSessions  int
+    Funnel       *Funnel
+    OnClose      func(*Session)
+    OnTransition func(from string, to string)
+
+    sessions     map[string]*Session
+    activity     *list.List
+    latest       time.Time
+    observedAt   time.Time
+}
+
+func NewSessionTracker(timeout time.Duration, maxSessions int, onClose func(*Session)) *SessionTracker {
+    return &SessionTracker{
+        Timeout:     timeout,
+        MaxSessions: maxSessions,
+        OnClose:     onClose,
+        sessions:    make(map[string]*Session),
+        activity:    list.New(),
+    }
+}
+
+// Return the number of sessions currently open.
+//
+func (self *SessionTracker) Len() int {
+    return len(self.sessions)
+}
+
+// Record a request, adding it to the client's current session (or starting a new one).
+//
+func (self *SessionTracker) Add(logLine *NcsaLog) {
+    if logLine.Timestamp.After(self.latest) {
+        self.latest = logLine.Timestamp
+        self.observedAt = time.Now()
+    }
+
+    key := logLine.ClientAddress() + "\x00" + logLine.UserAgent
+    session, ok := self.sessions[key]
+
+    if ok && logLine.Timestamp.Sub(session.End) > self.Timeout {
+        self.close(session)
+        ok = false
+    }
+
+    if !ok {
+        session = &Session{
+            Key:       key,
+            Client:    logLine.ClientAddress(),
+            UserAgent: logLine.UserAgent,
+            Start:     logLine.Timestamp,
+            End:       logLine.Timestamp,
+        }
+
+        session.element = self.activity.PushBack(session)
+        self.sessions[key] = session
+    }else{
+        self.activity.MoveToBack(session.element)
+    }
+
+    session.Hits += 1
+    session.Bytes += logLine.Size
+
+    if logLine.Timestamp.Before(session.Start) {
+        session.Start = logLine.Timestamp
+    }
+
+    if logLine.Timestamp.After(session.End) {
+        session.End = logLine.Timestamp
+    }
+
+    if IsPageView(logLine) {
+        page := RequestPath(logLine.Path)
+
+        if session.Pages == 0 {
+            session.EntryPage = page
+        }else if self.OnTransition != nil {
+            self.OnTransition(session.ExitPage, page)
+        }
+
+        session.ExitPage = page
+        session.Pages += 1
+        session.Funnel = self.Funnel.Advance(session.Funnel, page)
+    }
+
+    for len(self.sessions) > self.MaxSessions {
+        self.close(self.activity.Front().Value.(*Session))
+    }
+
+    self.Expire(self.latest)
+}
+
+// Close all sessions that have been inactive for longer than the timeout as of the given time.
+//
+func (self *SessionTracker) Expire(now time.Time) {
+    for element := self.activity.Front(); element != nil; {
+        session := element.Value.(*Session)
+        element = element.Next()
+
+        if now.Sub(session.End) > self.Timeout {
+            self.close(session)
+        }else{
+            break
+        }
+    }
+}
+
+// Expire sessions as of the latest log timestamp seen, plus however much (wall clock) time has
+// passed since it was seen.  This should be called periodically when following a live log, so
+// that sessions end even if no further requests arrive.
+//
+func (self *SessionTracker) Tick(wall time.Time) {
+    if !self.latest.IsZero() {
+        self.Expire(self.latest.Add(wall.Sub(self.observedAt)))
+    }
+}
+
+// Close all open sessions (e.g.: once the end of a historical log has been reached).
+//
+func (self *SessionTracker) Flush() {
+    for self.activity.Len() > 0 {
+        self.close(self.activity.Front().Value.(*Session))
+    }
+}
+
+func (self *SessionTracker) close(session *Session) {
+    self.activity.Remove(session.element)
+    delete(self.sessions, session.Key)
+
+    if self.OnClose != nil {
+        self.OnClose(session)
+    }
+}
+
+// Aggregate metrics over the sessions that ended within a bucket.
+//
+type SessionStats struct {
+    Count      uint64
+    Bounces    uint64
+    Pages      *QuantileSketch
+    Durations  *QuantileSketch
+    EntryPages *SpaceSaving
+    ExitPages  *SpaceSaving
+    Funnel     []uint64
+}
+
+func NewSessionStats(capacity int) *SessionStats {
+    return &SessionStats{
+        Pages:      NewQuantileSketch(),
+        Durations:  NewQuantileSketch(),
+        EntryPages: NewSpaceSaving(capacity),
+        ExitPages:  NewSpaceSaving(capacity),
+    }
+}
+
+func (self *SessionStats) Add(session *Session) {
+    self.Count += 1
+    self.Pages.Add(float64(session.Pages))
+    self.Durations.Add(session.Duration().Seconds())
+
+    if session.IsBounce() {
+        self.Bounces += 1
+    }
+
+    if session.EntryPage != `` {
+        self.EntryPages.Add(session.EntryPage)
+        self.ExitPages.Add(session.ExitPage)
+    }
+
+    for step := 0; step < session.Funnel; step++ {
+        self.addFunnel(step, 1)
+    }
+}
+
+func (self *SessionStats) Merge(other *SessionStats) {
+    if other == nil {
+        return
+    }
+
+    self.Count += other.Count
+    self.Bounces += other.Bounces
+    self.Pages.Merge(other.Pages)
+    self.Durations.Merge(other.Durations)
+    self.EntryPages.Merge(other.EntryPages)
+    self.ExitPages.Merge(other.ExitPages)
+
+    for step, count := range other.Funnel {
+        self.addFunnel(step, count)
+    }
+}
+
+func (self *SessionStats) addFunnel(step int, count uint64) {
+    for len(self.Funnel) <= step {
+        self.Funnel = append(self.Funnel, 0)
+    }
+
+    self.Funnel[step] += count
+}
+
+// Return the fraction of sessions that viewed at most one page.
+//
+func (self *SessionStats) BounceRate() float64 {
+    if self.Count == 0 {
+        return 0
+    }
+
+    return float64(self.Bounces) / float64(self.Count)
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func sessionTestLine(client string, offset time.Duration, path string) *NcsaLog {
+    start := time.Date(2016, time.March, 16, 2, 0, 0, 0, time.UTC)
+
+    return &NcsaLog{
+        Host:       client,
+        UserAgent:  `Mozilla/5.0`,
+        Timestamp:  start.Add(offset),
+        Method:     `GET`,
+        Path:       path,
+        StatusCode: 200,
+        Size:       100,
+    }
+}
+
+func TestSessionTrackerTimeout(t *testing.T) {
+    closed := make([]*Session, 0)
+    tracker := NewSessionTracker(30 * time.Minute, DEFAULT_MAX_SESSIONS, func(session *Session){
+        closed = append(closed, session)
+    })
+
+    tracker.Add(sessionTestLine(`10.0.0.1`, 0, `/`))
+    tracker.Add(sessionTestLine(`10.0.0.1`, time.Minute, `/style.css`))
+    tracker.Add(sessionTestLine(`10.0.0.1`, 2 * time.Minute, `/products?page=2`))
+    tracker.Add(sessionTestLine(`10.0.0.2`, 10 * time.Minute, `/about`))
+
+//  more than 30 minutes after either client was last seen, so both sessions end
+    tracker.Add(sessionTestLine(`10.0.0.1`, time.Hour, `/help`))
+
+    if len(closed) != 2 || tracker.Len() != 1 {
+        t.Fatalf("Expected 2 closed and 1 open session, got %d and %d", len(closed), tracker.Len())
+    }
+
+    first := closed[0]
+
+    if first.Hits != 3 || first.Pages != 2 || first.EntryPage != `/` || first.ExitPage != `/products` || first.Duration() != 2 * time.Minute {
+        t.Errorf("Unexpected first session: %+v", first)
+    }
+
+    if first.IsBounce() || !closed[1].IsBounce() {
+        t.Errorf("Expected only the second session to be a bounce")
+    }
+
+    tracker.Flush()
+
+    if len(closed) != 3 || tracker.Len() != 0 || closed[2].EntryPage != `/help` {
+        t.Errorf("Expected flushing to close the last session")
+    }
+}
+
+func TestSessionTrackerBounded(t *testing.T) {
+    closed := make([]string, 0)
+    tracker := NewSessionTracker(30 * time.Minute, 2, func(session *Session){
+        closed = append(closed, session.Client)
+    })
+
+    tracker.Add(sessionTestLine(`10.0.0.1`, 0, `/`))
+    tracker.Add(sessionTestLine(`10.0.0.2`, time.Second, `/`))
+    tracker.Add(sessionTestLine(`10.0.0.1`, 2 * time.Second, `/a`))
+    tracker.Add(sessionTestLine(`10.0.0.3`, 3 * time.Second, `/`))
+
+    if len(closed) != 1 || closed[0] != `10.0.0.2` {
+        t.Errorf("Expected the least recently active session to be closed, got %v", closed)
+    }
+}
+
+func TestSessionTrackerTick(t *testing.T) {
+    count := 0
+    tracker := NewSessionTracker(time.Minute, DEFAULT_MAX_SESSIONS, func(session *Session){
+        count += 1
+    })
+
+    tracker.Add(sessionTestLine(`10.0.0.1`, 0, `/`))
+    tracker.Tick(time.Now())
+
+    if count != 0 {
+        t.Errorf("Expected the session to remain open")
+    }
+
+    tracker.Tick(time.Now().Add(2 * time.Minute))
+
+    if count != 1 {
+        t.Errorf("Expected the session to expire once enough time had passed")
+    }
+}
+
+func TestSessionStatsMerge(t *testing.T) {
+    first := NewSessionStats(DEFAULT_TOPK_CAPACITY)
+    second := NewSessionStats(DEFAULT_TOPK_CAPACITY)
+
+    first.Add(&Session{ Pages: 1, EntryPage: `/`, ExitPage: `/` })
+    second.Add(&Session{ Pages: 3, EntryPage: `/`, ExitPage: `/checkout` })
+    second.Add(&Session{ Pages: 0 })
+
+    first.Merge(second)
+
+    if first.Count != 3 || first.Bounces != 2 {
+        t.Errorf("Expected 3 sessions and 2 bounces, got %d and %d", first.Count, first.Bounces)
+    }
+
+    if top := first.EntryPages.Top(1); len(top) != 1 || top[0].Key != `/` || top[0].Count != 2 {
+        t.Errorf("Expected '/' to be the top entry page, got %v", top)
+    }
+}
+
+func TestIsPageView(t *testing.T) {
+    for path, page := range map[string]bool{
+        `/`:               true,
+        `/products/42`:    true,
+        `/static/app.JS`:  false,
+        `/logo.png?v=3`:   false,
+        `/fonts/a.woff2`:  false,
+        `/users/john.doe`: true,
+        `/v1.2/docs`:      true,
+        `/report.pdf`:     true,
+        `/api/users`:      true,
+    }{
+        if v := IsPageView(&NcsaLog{ Method: `GET`, Path: path, StatusCode: 200 }); v != page {
+            t.Errorf("Expected page view of %s to be %v", path, page)
+        }
+    }
+
+    if IsPageView(&NcsaLog{ Method: `GET`, Path: `/missing`, StatusCode: 404 }) {
+        t.Errorf("Expected a 404 not to be a page view")
+    }
+}
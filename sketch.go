@@ -0,0 +1,143 @@
+package main
+
+import (
+    "math"
+    "sort"
+)
+
+const DEFAULT_SKETCH_ACCURACY = 0.01
+
+// A streaming quantile sketch over non-negative values, using logarithmically-sized buckets
+// (in the style of DDSketch) so that every quantile estimate is within a fixed relative error
+// of the true value.  Sketches with the same accuracy can be merged losslessly, which lets
+// per-bucket sketches be combined into any window.
+//
+type QuantileSketch struct {
+    Accuracy float64
+    Count    uint64
+    Sum      float64
+    Min      float64
+    Max      float64
+
+    gamma    float64
+    logGamma float64
+    zeroes   uint64
+    buckets  map[int]uint64
+}
+
+func NewQuantileSketch() *QuantileSketch {
+    return NewQuantileSketchWithAccuracy(DEFAULT_SKETCH_ACCURACY)
+}
+
+func NewQuantileSketchWithAccuracy(accuracy float64) *QuantileSketch {
+    gamma := (1 + accuracy) / (1 - accuracy)
+
+    return &QuantileSketch{
+        Accuracy: accuracy,
+        gamma:    gamma,
+        logGamma: math.Log(gamma),
+        buckets:  make(map[int]uint64),
+    }
+}
+
+// Record a single value.  Negative values are treated as zero.
+//
+func (self *QuantileSketch) Add(value float64) {
+    if value < 0 {
+        value = 0
+    }
+
+    if self.Count == 0 || value < self.Min {
+        self.Min = value
+    }
+
+    if self.Count == 0 || value > self.Max {
+        self.Max = value
+    }
+
+    self.Count += 1
+    self.Sum += value
+
+    if value == 0 {
+        self.zeroes += 1
+    }else{
+        self.buckets[int(math.Ceil(math.Log(value) / self.logGamma))] += 1
+    }
+}
+
+// Accumulate all values recorded by another sketch into this one.
+//
+func (self *QuantileSketch) Merge(other *QuantileSketch) {
+    if other == nil || other.Count == 0 {
+        return
+    }
+
+    if self.Count == 0 || other.Min < self.Min {
+        self.Min = other.Min
+    }
+
+    if self.Count == 0 || other.Max > self.Max {
+        self.Max = other.Max
+    }
+
+    self.Count += other.Count
+    self.Sum += other.Sum
+    self.zeroes += other.zeroes
+
+    for idx, count := range other.buckets {
+        self.buckets[idx] += count
+    }
+}
+
+// Return the arithmetic mean of all recorded values (or zero if none were recorded).
+//
+func (self *QuantileSketch) Mean() float64 {
+    if self.Count == 0 {
+        return 0
+    }
+
+    return self.Sum / float64(self.Count)
+}
+
+// Return an estimate of the value at the given quantile (0 <= q <= 1).
+//
+func (self *QuantileSketch) Quantile(q float64) float64 {
+    if self.Count == 0 {
+        return 0
+    }
+
+    if q <= 0 {
+        return self.Min
+    }else if q >= 1 {
+        return self.Max
+    }
+
+    rank := uint64(q * float64(self.Count - 1))
+
+    if rank < self.zeroes {
+        return 0
+    }
+
+    indices := make([]int, 0, len(self.buckets))
+
+    for idx := range self.buckets {
+        indices = append(indices, idx)
+    }
+
+    sort.Ints(indices)
+
+    seen := self.zeroes
+
+    for _, idx := range indices {
+        seen += self.buckets[idx]
+
+        if seen > rank {
+            estimate := 2 * math.Pow(self.gamma, float64(idx)) / (self.gamma + 1)
+
+        //  estimates never fall outside of what was actually observed
+            return math.Max(self.Min, math.Min(self.Max, estimate))
+        }
+    }
+
+    return self.Max
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+    "math"
+    "testing"
+)
+
+func assertWithinAccuracy(t *testing.T, label string, expected float64, actual float64) {
+    if math.Abs(actual - expected) > (expected * DEFAULT_SKETCH_ACCURACY) + 1e-9 {
+        t.Errorf("%s: expected ~%f, got %f", label, expected, actual)
+    }
+}
+
+func TestQuantileSketchEmpty(t *testing.T) {
+    sketch := NewQuantileSketch()
+
+    if sketch.Quantile(0.5) != 0 || sketch.Mean() != 0 {
+        t.Errorf("Expected zero-valued estimates from an empty sketch")
+    }
+}
+
+func TestQuantileSketchQuantiles(t *testing.T) {
+    sketch := NewQuantileSketch()
+
+    for i := 1; i <= 1000; i++ {
+        sketch.Add(float64(i))
+    }
+
+    assertWithinAccuracy(t, `p50`, 500, sketch.Quantile(0.5))
+    assertWithinAccuracy(t, `p90`, 900, sketch.Quantile(0.9))
+    assertWithinAccuracy(t, `p99`, 990, sketch.Quantile(0.99))
+
+    if sketch.Quantile(1) != 1000 || sketch.Max != 1000 {
+        t.Errorf("Expected exact max=1000, got %f", sketch.Quantile(1))
+    }
+
+    if sketch.Mean() != 500.5 {
+        t.Errorf("Expected mean=500.5, got %f", sketch.Mean())
+    }
+}
+
+func TestQuantileSketchMerge(t *testing.T) {
+    low := NewQuantileSketch()
+    high := NewQuantileSketch()
+
+    for i := 1; i <= 500; i++ {
+        low.Add(float64(i))
+        high.Add(float64(i + 500))
+    }
+
+    high.Add(0)
+
+    low.Merge(high)
+
+    if low.Count != 1001 {
+        t.Errorf("Expected count=1001, got %d", low.Count)
+    }
+
+    if low.Min != 0 || low.Max != 1000 {
+        t.Errorf("Expected min=0 max=1000, got min=%f max=%f", low.Min, low.Max)
+    }
+
+    assertWithinAccuracy(t, `merged p50`, 500, low.Quantile(0.5))
+    assertWithinAccuracy(t, `merged p90`, 900, low.Quantile(0.9))
+}
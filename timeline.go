@@ -0,0 +1,275 @@
+package main
+
+import (
+    "fmt"
+    "sort"
+    "time"
+
+    log "github.com/Sirupsen/logrus"
+)
+
+const TIMELINE_AUTO            = `auto`
+const DEFAULT_TIMELINE_BUCKETS = 60
+
+// The most buckets a timeline of a fixed width may have; beyond this, buckets are widened as
+// they would be for an automatically-sized timeline.
+//
+const MAX_TIMELINE_BUCKETS = 10000
+
+// The most distinct sections given their own counts; requests for any others are counted
+// under TIMELINE_OTHER.
+//
+const DEFAULT_TIMELINE_SECTIONS = 100
+const TIMELINE_OTHER            = `(other)`
+
+// Bucket widths used when sizing timeline buckets automatically, each a multiple of the one
+// before it (so that buckets can be combined as the time span grows).
+//
+var TIMELINE_WIDTHS = []time.Duration{
+    time.Second,
+    5 * time.Second,
+    10 * time.Second,
+    30 * time.Second,
+    time.Minute,
+    5 * time.Minute,
+    10 * time.Minute,
+    30 * time.Minute,
+    time.Hour,
+    2 * time.Hour,
+    6 * time.Hour,
+    12 * time.Hour,
+    24 * time.Hour,
+    7 * 24 * time.Hour,
+}
+
+// Counts by section and status family (or class).
+//
+type TimelineCounts map[string]map[string]uint64
+
+func (self TimelineCounts) Add(section string, family string, count uint64) {
+    families, ok := self[section]
+
+    if !ok {
+        families = make(map[string]uint64)
+        self[section] = families
+    }
+
+    families[family] += count
+}
+
+// Return the total count of the given section, and of each of its families.
+//
+func (self TimelineCounts) Section(section string) (uint64, map[string]uint64) {
+    var total uint64
+
+    for _, count := range self[section] {
+        total += count
+    }
+
+    return total, self[section]
+}
+
+// Return the total count across all sections, and of each family.
+//
+func (self TimelineCounts) Totals() (uint64, map[string]uint64) {
+    var total uint64
+    families := make(map[string]uint64)
+
+    for _, counts := range self {
+        for family, count := range counts {
+            families[family] += count
+            total += count
+        }
+    }
+
+    return total, families
+}
+
+// Counts requests per section and status family in buckets spanning the timestamps of an
+// entire input (e.g.: an archived log file).  Buckets are either of a fixed width, or are sized
+// automatically: starting at one second, buckets are combined into the next of the
+// TIMELINE_WIDTHS whenever the span of the input would need more than MaxBuckets of them.
+// Fixed-width buckets are combined in the same way if there would be more than
+// MAX_TIMELINE_BUCKETS of them.
+//
+type Timeline struct {
+    Width       time.Duration
+    Auto        bool
+    MaxBuckets  int
+    MaxSections int
+    Buckets     map[int64]TimelineCounts
+    Earliest    time.Time
+    Latest      time.Time
+
+    sections    map[string]bool
+}
+
+// Create a timeline from a bucket width specification: either "auto" or a duration (e.g.: "1m").
+//
+func ParseTimeline(spec string, maxBuckets int) (*Timeline, error) {
+    timeline := &Timeline{
+        MaxBuckets:  maxBuckets,
+        MaxSections: DEFAULT_TIMELINE_SECTIONS,
+        Buckets:     make(map[int64]TimelineCounts),
+        sections:    make(map[string]bool),
+    }
+
+    if spec == TIMELINE_AUTO {
+        if maxBuckets < 1 {
+            return nil, fmt.Errorf("Invalid number of timeline buckets %d: must be at least 1", maxBuckets)
+        }
+
+        timeline.Auto = true
+        timeline.Width = TIMELINE_WIDTHS[0]
+    }else if width, err := ParseDuration(spec); err == nil && width > 0 {
+        timeline.Width = width
+        timeline.MaxBuckets = MAX_TIMELINE_BUCKETS
+    }else{
+        return nil, fmt.Errorf("Invalid timeline '%s': must be \"auto\" or a positive duration (e.g.: \"1m\")", spec)
+    }
+
+    return timeline, nil
+}
+
+// Record a request, which was grouped into the given section.
+//
+func (self *Timeline) Add(section string, logLine *NcsaLog) {
+    if self.Earliest.IsZero() || logLine.Timestamp.Before(self.Earliest) {
+        self.Earliest = logLine.Timestamp
+    }
+
+    if logLine.Timestamp.After(self.Latest) {
+        self.Latest = logLine.Timestamp
+    }
+
+    for self.Len() > self.MaxBuckets {
+        self.widen()
+    }
+
+    if !self.sections[section] {
+        if len(self.sections) < self.MaxSections {
+            self.sections[section] = true
+        }else{
+            section = TIMELINE_OTHER
+        }
+    }
+
+    self.bucket(logLine.Timestamp).Add(section, StatusFamily(logLine.StatusCode), 1)
+}
+
+// Return the number of buckets spanning the earliest to the latest request.
+//
+func (self *Timeline) Len() int {
+    if self.Earliest.IsZero() {
+        return 0
+    }
+
+    return int(self.Latest.Truncate(self.Width).Sub(self.Earliest.Truncate(self.Width)) / self.Width) + 1
+}
+
+// Return the start time of every bucket from the earliest to the latest request, including
+// any in which nothing was requested.
+//
+func (self *Timeline) Times() []time.Time {
+    times := make([]time.Time, 0, self.Len())
+
+    if self.Earliest.IsZero() {
+        return times
+    }
+
+    for t := self.Earliest.Truncate(self.Width); !t.After(self.Latest); t = t.Add(self.Width) {
+        times = append(times, t)
+    }
+
+    return times
+}
+
+// Return the counts of the bucket starting at the given time (which may be empty).
+//
+func (self *Timeline) Counts(start time.Time) TimelineCounts {
+    if counts, ok := self.Buckets[start.UnixNano()]; ok {
+        return counts
+    }
+
+    return TimelineCounts{}
+}
+
+// Return the sections seen, busiest first (with any TIMELINE_OTHER requests last).
+//
+func (self *Timeline) Sections() []string {
+    totals := make(map[string]uint64)
+
+    for _, counts := range self.Buckets {
+        for section, _ := range counts {
+            total, _ := counts.Section(section)
+            totals[section] += total
+        }
+    }
+
+    sections := make([]string, 0, len(totals))
+
+    for section, _ := range totals {
+        if section != TIMELINE_OTHER {
+            sections = append(sections, section)
+        }
+    }
+
+    sort.Slice(sections, func(i, j int) bool {
+        if totals[sections[i]] == totals[sections[j]] {
+            return sections[i] < sections[j]
+        }
+
+        return totals[sections[i]] > totals[sections[j]]
+    })
+
+    if _, ok := totals[TIMELINE_OTHER]; ok {
+        sections = append(sections, TIMELINE_OTHER)
+    }
+
+    return sections
+}
+
+func (self *Timeline) bucket(t time.Time) TimelineCounts {
+    key := t.Truncate(self.Width).UnixNano()
+    counts, ok := self.Buckets[key]
+
+    if !ok {
+        counts = make(TimelineCounts)
+        self.Buckets[key] = counts
+    }
+
+    return counts
+}
+
+// Move to the next wider bucket size (the next of the TIMELINE_WIDTHS that is a multiple of the
+// current one, or else double it), combining the existing buckets into the new ones.
+//
+func (self *Timeline) widen() {
+    width := self.Width * 2
+
+    for _, candidate := range TIMELINE_WIDTHS {
+        if candidate > self.Width && candidate % self.Width == 0 {
+            width = candidate
+            break
+        }
+    }
+
+    if !self.Auto {
+        log.Warnf("Timeline would have more than %d buckets of %v; widening them to %v", self.MaxBuckets, self.Width, width)
+    }
+
+    self.Width = width
+
+    buckets := self.Buckets
+    self.Buckets = make(map[int64]TimelineCounts)
+
+    for start, counts := range buckets {
+        merged := self.bucket(time.Unix(0, start))
+
+        for section, families := range counts {
+            for family, count := range families {
+                merged.Add(section, family, count)
+            }
+        }
+    }
+}
@@ -0,0 +1,136 @@
+package main
+
+import (
+    "bytes"
+    "strings"
+    "testing"
+    "time"
+)
+
+func timelineTestLine(offset time.Duration, status uint) *NcsaLog {
+    return &NcsaLog{
+        Timestamp:  time.Date(2024, time.March, 18, 10, 0, 0, 0, time.UTC).Add(offset),
+        StatusCode: status,
+    }
+}
+
+func TestTimelineFixed(t *testing.T) {
+    timeline, err := ParseTimeline(`1m`, DEFAULT_TIMELINE_BUCKETS)
+
+    if err != nil {
+        t.Fatalf("Failed to create timeline: %v", err)
+    }
+
+    timeline.Add(`/api`, timelineTestLine(10 * time.Second, 200))
+    timeline.Add(`/api`, timelineTestLine(50 * time.Second, 503))
+    timeline.Add(`/img`, timelineTestLine(3 * time.Minute, 200))
+
+    times := timeline.Times()
+
+    if len(times) != 4 || timeline.Len() != 4 {
+        t.Fatalf("Expected 4 buckets (including 2 empty ones), got %d", len(times))
+    }
+
+    if total, families := timeline.Counts(times[0]).Totals(); total != 2 || families[`5xx`] != 1 {
+        t.Errorf("Expected 2 requests (1 5xx) in the first bucket, got %d (%v)", total, families)
+    }
+
+    if total, _ := timeline.Counts(times[1]).Totals(); total != 0 {
+        t.Errorf("Expected the second bucket to be empty, got %d", total)
+    }
+
+    if sections := timeline.Sections(); strings.Join(sections, `,`) != `/api,/img` {
+        t.Errorf("Expected sections /api,/img, got %v", sections)
+    }
+
+    var buffer bytes.Buffer
+
+    if err := WriteTimelineCSV(&buffer, timeline); err != nil {
+        t.Fatalf("Failed to write CSV: %v", err)
+    }
+
+    expected := strings.Join([]string{
+        `time,key,count,1xx,2xx,3xx,4xx,5xx,???`,
+        `2024-03-18T10:00:00Z,*,2,0,1,0,0,1,0`,
+        `2024-03-18T10:00:00Z,/api,2,0,1,0,0,1,0`,
+        `2024-03-18T10:01:00Z,*,0,0,0,0,0,0,0`,
+        `2024-03-18T10:02:00Z,*,0,0,0,0,0,0,0`,
+        `2024-03-18T10:03:00Z,*,1,0,1,0,0,0,0`,
+        `2024-03-18T10:03:00Z,/img,1,0,1,0,0,0,0`,
+    }, "\n") + "\n"
+
+    if buffer.String() != expected {
+        t.Errorf("Unexpected CSV output:\n%s", buffer.String())
+    }
+}
+
+func TestTimelineAuto(t *testing.T) {
+    timeline, err := ParseTimeline(TIMELINE_AUTO, 10)
+
+    if err != nil {
+        t.Fatalf("Failed to create timeline: %v", err)
+    }
+
+    for i := 0; i < 120; i++ {
+        timeline.Add(`/`, timelineTestLine(time.Duration(i) * 30 * time.Second, 200))
+    }
+
+    if timeline.Width != 10 * time.Minute {
+        t.Errorf("Expected buckets to widen to 10m, got %v", timeline.Width)
+    }
+
+    if n := timeline.Len(); n != 6 || len(timeline.Buckets) != 6 {
+        t.Errorf("Expected 6 buckets, got %d (%d stored)", n, len(timeline.Buckets))
+    }
+
+    var total uint64
+
+    for _, start := range timeline.Times() {
+        count, _ := timeline.Counts(start).Totals()
+        total += count
+    }
+
+    if total != 120 {
+        t.Errorf("Expected all 120 requests to be kept while widening, got %d", total)
+    }
+
+    for _, spec := range []string{ `0s`, `-1m`, `soon` } {
+        if _, err := ParseTimeline(spec, 10); err == nil {
+            t.Errorf("Expected '%s' to be rejected", spec)
+        }
+    }
+}
+
+func TestSparkline(t *testing.T) {
+    if v := sparkline([]uint64{ 0, 1, 4, 8, 2 }); v != ` ▁▄█▂` {
+        t.Errorf("Unexpected sparkline '%s'", v)
+    }
+
+    if v := sparkline([]uint64{ 0, 0 }); v != `  ` {
+        t.Errorf("Unexpected sparkline '%s'", v)
+    }
+}
+
+func TestTimelineLimits(t *testing.T) {
+    timeline, err := ParseTimeline(`60`, DEFAULT_TIMELINE_BUCKETS)
+
+    if err != nil || timeline.Width != time.Minute {
+        t.Fatalf("Expected a bare number of seconds to be accepted as the width, got %v (%v)", timeline, err)
+    }
+
+    timeline.MaxBuckets = 10
+    timeline.MaxSections = 2
+
+    for i, section := range []string{ `/a`, `/b`, `/c`, `/d`, `/a` } {
+        timeline.Add(section, timelineTestLine(time.Duration(i) * 5 * time.Minute, 200))
+    }
+
+//  20 minutes of 1m buckets is too many, so the next wider width (5m) is used instead
+    if timeline.Width != 5 * time.Minute || timeline.Len() != 5 {
+        t.Errorf("Expected 5 buckets of 5m, got %d of %v", timeline.Len(), timeline.Width)
+    }
+
+    if sections := timeline.Sections(); strings.Join(sections, `,`) != `/a,/b,` + TIMELINE_OTHER {
+        t.Errorf("Expected sections /a,/b,%s, got %v", TIMELINE_OTHER, sections)
+    }
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+    "container/heap"
+    "sort"
+)
+
+const DEFAULT_TOPK_CAPACITY = 1000
+
+// An approximate count for a single key tracked by a SpaceSaving sketch.  The true count is
+// guaranteed to lie between (Count - Error) and Count.
+//
+type HeavyHitter struct {
+    Key   string
+    Count uint64
+    Error uint64
+
+    index int
+}
+
+type heavyHitterHeap []*HeavyHitter
+
+func (self heavyHitterHeap) Len() int           { return len(self) }
+func (self heavyHitterHeap) Less(i, j int) bool { return self[i].Count < self[j].Count }
+
+func (self heavyHitterHeap) Swap(i, j int) {
+    self[i], self[j] = self[j], self[i]
+    self[i].index = i
+    self[j].index = j
+}
+
+func (self *heavyHitterHeap) Push(x interface{}) {
+    hitter := x.(*HeavyHitter)
+    hitter.index = len(*self)
+    *self = append(*self, hitter)
+}
+
+func (self *heavyHitterHeap) Pop() interface{} {
+    old := *self
+    hitter := old[len(old) - 1]
+    *self = old[:len(old) - 1]
+    return hitter
+}
+
+// A SpaceSaving sketch tracks the most frequent keys in a stream using a fixed number of
+// counters, regardless of how many distinct keys are seen.  When a new key arrives and all
+// counters are in use, the smallest counter is reassigned to it (inheriting its count as the
+// error bound).  Any key occurring more than Total/Capacity times is guaranteed to be tracked.
+//
+type SpaceSaving struct {
+    Capacity int
+    Total    uint64
+
+    counters map[string]*HeavyHitter
+    heap     heavyHitterHeap
+}
+
+func NewSpaceSaving(capacity int) *SpaceSaving {
+    return &SpaceSaving{
+        Capacity: capacity,
+        counters: make(map[string]*HeavyHitter),
+        heap:     make(heavyHitterHeap, 0),
+    }
+}
+
+// Count one occurrence of the given key.
+//
+func (self *SpaceSaving) Add(key string) {
+    self.AddCount(key, 1, 0)
+}
+
+// Count the given number of occurrences of a key, with an existing error bound.
+//
+func (self *SpaceSaving) AddCount(key string, count uint64, err uint64) {
+    self.Total += count
+
+    if hitter, ok := self.counters[key]; ok {
+        hitter.Count += count
+        hitter.Error += err
+        heap.Fix(&self.heap, hitter.index)
+        return
+    }
+
+    if len(self.heap) < self.Capacity {
+        hitter := &HeavyHitter{
+            Key:   key,
+            Count: count,
+            Error: err,
+        }
+
+        self.counters[key] = hitter
+        heap.Push(&self.heap, hitter)
+        return
+    }
+
+//  evict the smallest counter, and give its count to the new key as an error bound
+    smallest := self.heap[0]
+    delete(self.counters, smallest.Key)
+
+    smallest.Error = smallest.Count + err
+    smallest.Count = smallest.Count + count
+    smallest.Key = key
+
+    self.counters[key] = smallest
+    heap.Fix(&self.heap, smallest.index)
+}
+
+// Return the estimated count of the given key, if it is being tracked.
+//
+func (self *SpaceSaving) Get(key string) (HeavyHitter, bool) {
+    if hitter, ok := self.counters[key]; ok {
+        return *hitter, true
+    }
+
+    return HeavyHitter{}, false
+}
+
+// The smallest count that is currently tracked, or zero if there are free counters.  Any key
+// not being tracked can have occurred at most this many times.
+//
+func (self *SpaceSaving) MinCount() uint64 {
+    if len(self.heap) < self.Capacity || len(self.heap) == 0 {
+        return 0
+    }
+
+    return self.heap[0].Count
+}
+
+// Accumulate another sketch into this one.  Keys that are missing from one sketch are assumed
+// to have occurred as often as that sketch's smallest counter, which is added to their error.
+//
+func (self *SpaceSaving) Merge(other *SpaceSaving) {
+    if other == nil || other.Total == 0 {
+        return
+    }
+
+    selfMin := self.MinCount()
+    otherMin := other.MinCount()
+    merged := make(map[string]*HeavyHitter)
+
+    for _, sketch := range []*SpaceSaving{ self, other } {
+        for key := range sketch.counters {
+            if _, ok := merged[key]; ok {
+                continue
+            }
+
+            hitter := &HeavyHitter{
+                Key: key,
+            }
+
+            if h, ok := self.counters[key]; ok {
+                hitter.Count += h.Count
+                hitter.Error += h.Error
+            }else{
+                hitter.Count += selfMin
+                hitter.Error += selfMin
+            }
+
+            if h, ok := other.counters[key]; ok {
+                hitter.Count += h.Count
+                hitter.Error += h.Error
+            }else{
+                hitter.Count += otherMin
+                hitter.Error += otherMin
+            }
+
+            merged[key] = hitter
+        }
+    }
+
+    total := self.Total + other.Total
+    hitters := make([]*HeavyHitter, 0, len(merged))
+
+    for _, hitter := range merged {
+        hitters = append(hitters, hitter)
+    }
+
+    sortHeavyHitters(hitters)
+
+    if len(hitters) > self.Capacity {
+        hitters = hitters[:self.Capacity]
+    }
+
+    self.counters = make(map[string]*HeavyHitter)
+    self.heap = make(heavyHitterHeap, 0, len(hitters))
+
+    for _, hitter := range hitters {
+        self.counters[hitter.Key] = hitter
+        heap.Push(&self.heap, hitter)
+    }
+
+    self.Total = total
+}
+
+// Return (up to) the n most frequent keys, most frequent first.
+//
+func (self *SpaceSaving) Top(n int) []HeavyHitter {
+    hitters := make([]*HeavyHitter, 0, len(self.heap))
+
+    for _, hitter := range self.heap {
+        hitters = append(hitters, hitter)
+    }
+
+    sortHeavyHitters(hitters)
+
+    if n > 0 && len(hitters) > n {
+        hitters = hitters[:n]
+    }
+
+    top := make([]HeavyHitter, len(hitters))
+
+    for i, hitter := range hitters {
+        top[i] = *hitter
+    }
+
+    return top
+}
+
+func sortHeavyHitters(hitters []*HeavyHitter) {
+    sort.Slice(hitters, func(i, j int) bool {
+        if hitters[i].Count == hitters[j].Count {
+            return hitters[i].Key < hitters[j].Key
+        }
+
+        return hitters[i].Count > hitters[j].Count
+    })
+}
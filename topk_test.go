@@ -0,0 +1,86 @@
+package main
+
+import (
+    "fmt"
+    "testing"
+)
+
+func TestSpaceSavingExact(t *testing.T) {
+    sketch := NewSpaceSaving(10)
+
+    for i, key := range []string{ `a`, `b`, `c` } {
+        for j := 0; j <= i; j++ {
+            sketch.Add(key)
+        }
+    }
+
+    top := sketch.Top(2)
+
+    if len(top) != 2 || top[0].Key != `c` || top[0].Count != 3 || top[1].Key != `b` || top[1].Count != 2 {
+        t.Errorf("Unexpected top 2: %+v", top)
+    }
+
+    if top[0].Error != 0 {
+        t.Errorf("Expected exact counts while under capacity, got error=%d", top[0].Error)
+    }
+}
+
+func TestSpaceSavingHeavyHitters(t *testing.T) {
+    sketch := NewSpaceSaving(20)
+
+//  a few heavy keys hidden in a long tail of distinct keys
+    for i := 0; i < 10000; i++ {
+        switch {
+        case i % 10 == 0:
+            sketch.Add(`heavy-1`)
+        case i % 10 == 1:
+            sketch.Add(`heavy-2`)
+        default:
+            sketch.Add(fmt.Sprintf("tail-%d", i))
+        }
+    }
+
+    top := sketch.Top(2)
+
+    for i, key := range []string{ `heavy-1`, `heavy-2` } {
+        if top[i].Key != key {
+            t.Errorf("Expected %s at position %d, got %+v", key, i, top)
+        }
+
+        if top[i].Count < 1000 || top[i].Count - top[i].Error > 1000 {
+            t.Errorf("True count 1000 is not within bounds for %+v", top[i])
+        }
+    }
+
+    if sketch.Total != 10000 {
+        t.Errorf("Expected total=10000, got %d", sketch.Total)
+    }
+}
+
+func TestSpaceSavingMerge(t *testing.T) {
+    a := NewSpaceSaving(3)
+    b := NewSpaceSaving(3)
+
+    for i := 0; i < 5; i++ {
+        a.Add(`x`)
+        b.Add(`x`)
+        b.Add(`y`)
+    }
+
+    a.Add(`z`)
+    a.Merge(b)
+
+    top := a.Top(0)
+
+    if top[0].Key != `x` || top[0].Count != 10 || top[0].Error != 0 {
+        t.Errorf("Expected x=10 exactly, got %+v", top[0])
+    }
+
+    if top[1].Key != `y` || top[1].Count != 5 {
+        t.Errorf("Expected y=5, got %+v", top[1])
+    }
+
+    if a.Total != 16 {
+        t.Errorf("Expected total=16, got %d", a.Total)
+    }
+}
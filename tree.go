@@ -0,0 +1,196 @@
+package main
+
+import (
+    "sort"
+    "strings"
+)
+
+// The most children any node of a SectionTree has; requests for any other children are
+// counted under a TREE_OTHER child instead.
+//
+const DEFAULT_TREE_CHILDREN = 100
+const TREE_OTHER            = `(other)`
+
+// A node in a SectionTree, holding the request count and status codes of every request at or
+// below its path.  Only those are recorded (the sketches and other breakdowns of the Stat are
+// left unallocated), since nodes are numerous and nothing else is shown for them.
+//
+type SectionNode struct {
+    Path     string
+    Stat     *LogStatistic
+    Children map[string]*SectionNode
+}
+
+func NewSectionNode(path string) *SectionNode {
+    return &SectionNode{
+        Path:     path,
+        Stat:     NewLogStatistic(path),
+        Children: make(map[string]*SectionNode),
+    }
+}
+
+// Return this node's children, busiest first.
+//
+func (self *SectionNode) SortedChildren() []*SectionNode {
+    children := make([]*SectionNode, 0, len(self.Children))
+
+    for _, child := range self.Children {
+        children = append(children, child)
+    }
+
+    sort.Slice(children, func(i, j int) bool {
+        if children[i].Stat.Count == children[j].Stat.Count {
+            return children[i].Path < children[j].Path
+        }
+
+        return children[i].Stat.Count > children[j].Stat.Count
+    })
+
+    return children
+}
+
+func (self *SectionNode) add(logLine *NcsaLog) {
+    self.Stat.Count += 1
+    addCount(&self.Stat.Statuses, logLine.StatusCode, 1)
+}
+
+// Return the child for the given segment, creating it if necessary.  Once the node has
+// maxChildren children, any others are folded into a TREE_OTHER child, which is returned
+// along with false.
+//
+func (self *SectionNode) child(segment string, maxChildren int) (*SectionNode, bool) {
+    if child, ok := self.Children[segment]; ok {
+        return child, true
+    }
+
+    folded := false
+
+    if len(self.Children) >= maxChildren {
+        segment = TREE_OTHER
+        folded = true
+
+        if child, ok := self.Children[segment]; ok {
+            return child, false
+        }
+    }
+
+    child := NewSectionNode(strings.TrimSuffix(self.Path, `/`) + `/` + segment)
+    self.Children[segment] = child
+
+    return child, !folded
+}
+
+func (self *SectionNode) merge(other *SectionNode, maxChildren int) {
+    self.Stat.Merge(other.Stat)
+
+    for segment, otherChild := range other.Children {
+    //  nothing is kept below the TREE_OTHER node
+        if child, ok := self.child(segment, maxChildren); ok {
+            child.merge(otherChild, maxChildren)
+        }else{
+            child.Stat.Merge(otherChild.Stat)
+        }
+    }
+}
+
+// A prefix tree of request paths (e.g.: "/api" -> "/api/v1" -> "/api/v1/users"), maintaining
+// counts and status families at every level so that load can be drilled down into.  Paths are
+// normalized into routes (see PathNormalizer) and truncated to MaxDepth segments, and each node
+// has at most MaxChildren children (with the rest combined under TREE_OTHER, below which
+// nothing is kept), so that the tree stays bounded in size however many distinct paths it sees.
+//
+type SectionTree struct {
+    MaxDepth    int
+    MaxChildren int
+    Root        *SectionNode
+}
+
+func NewSectionTree(maxDepth int) *SectionTree {
+    return &SectionTree{
+        MaxDepth:    maxDepth,
+        MaxChildren: DEFAULT_TREE_CHILDREN,
+        Root:        NewSectionNode(`/`),
+    }
+}
+
+// Record a log line at every node along its path.
+//
+func (self *SectionTree) Add(logLine *NcsaLog) {
+    node := self.Root
+    node.add(logLine)
+
+    segments := PathSegments(PathTemplates.Template(logLine.Path))
+
+    if len(segments) > self.MaxDepth {
+        segments = segments[:self.MaxDepth]
+    }
+
+    for _, segment := range segments {
+        child, ok := node.child(segment, self.MaxChildren)
+        child.add(logLine)
+
+    //  nothing is kept below the TREE_OTHER node
+        if !ok {
+            break
+        }
+
+        node = child
+    }
+}
+
+// Accumulate all paths from another tree into this one.
+//
+func (self *SectionTree) Merge(other *SectionTree) {
+    self.Root.merge(other.Root, self.MaxChildren)
+}
+
+// A single line of a pruned tree.
+//
+type SectionTreeRow struct {
+    Depth  int
+    Node   *SectionNode
+    Others uint64
+}
+
+// Flatten the tree (depth-first, busiest children first) down to the given depth, omitting
+// any node whose share of the total hits is below minShare.  Omitted siblings are summarized
+// by a row with a nil Node whose Others field holds their combined hit count.
+//
+func (self *SectionTree) Prune(depth int, minShare float64) []SectionTreeRow {
+    rows := make([]SectionTreeRow, 0)
+    total := self.Root.Stat.Count
+
+    var walk func(*SectionNode, int)
+
+    walk = func(node *SectionNode, level int) {
+        rows = append(rows, SectionTreeRow{
+            Depth: level,
+            Node:  node,
+        })
+
+        if level >= depth {
+            return
+        }
+
+        var others uint64
+
+        for _, child := range node.SortedChildren() {
+            if total > 0 && float64(child.Stat.Count) / float64(total) >= minShare {
+                walk(child, level + 1)
+            }else{
+                others += child.Stat.Count
+            }
+        }
+
+        if others > 0 {
+            rows = append(rows, SectionTreeRow{
+                Depth:  level + 1,
+                Others: others,
+            })
+        }
+    }
+
+    walk(self.Root, 0)
+
+    return rows
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+    "testing"
+)
+
+func TestSectionTree(t *testing.T) {
+    tree := NewSectionTree(3)
+
+    for i := 0; i < 6; i++ {
+        tree.Add(&NcsaLog{ Path: `/api/v1/users/42/settings`, StatusCode: 200 })
+    }
+
+    tree.Add(&NcsaLog{ Path: `/api/v2/users`, StatusCode: 500 })
+    tree.Add(&NcsaLog{ Path: `/help`, StatusCode: 404 })
+
+    if tree.Root.Stat.Count != 8 {
+        t.Errorf("Expected 8 hits at the root, got %d", tree.Root.Stat.Count)
+    }
+
+    api := tree.Root.Children[`api`]
+
+    if api == nil || api.Stat.Count != 7 {
+        t.Fatalf("Expected 7 hits under /api, got %+v", api)
+    }
+
+    if users := api.Children[`v1`].Children[`users`]; users == nil || users.Path != `/api/v1/users` {
+        t.Errorf("Expected a node for /api/v1/users, got %+v", users)
+    }else if len(users.Children) != 0 {
+        t.Errorf("Expected the tree to be truncated at a depth of 3")
+    }
+
+    if fam := api.Stat.GroupByStatusFamily(); fam[`2xx`] != 6 || fam[`5xx`] != 1 {
+        t.Errorf("Unexpected status families under /api: %+v", fam)
+    }
+
+    other := NewSectionTree(3)
+    other.Add(&NcsaLog{ Path: `/help`, StatusCode: 200 })
+    tree.Merge(other)
+
+    if help := tree.Root.Children[`help`]; help.Stat.Count != 2 {
+        t.Errorf("Expected 2 hits under /help after merging, got %d", help.Stat.Count)
+    }
+}
+
+func TestSectionTreePrune(t *testing.T) {
+    tree := NewSectionTree(3)
+
+    for i := 0; i < 18; i++ {
+        tree.Add(&NcsaLog{ Path: `/api/v1/users` })
+    }
+
+    tree.Add(&NcsaLog{ Path: `/help` })
+    tree.Add(&NcsaLog{ Path: `/about` })
+
+    rows := tree.Prune(2, 0.1)
+    paths := make([]string, 0)
+
+    for _, row := range rows {
+        if row.Node == nil {
+            paths = append(paths, `(other)`)
+
+            if row.Others != 2 {
+                t.Errorf("Expected 2 pruned hits, got %d", row.Others)
+            }
+        }else{
+            paths = append(paths, row.Node.Path)
+        }
+    }
+
+    expected := []string{ `/`, `/api`, `/api/v1`, `(other)` }
+
+    if len(paths) != len(expected) {
+        t.Fatalf("Expected rows %+v, got %+v", expected, paths)
+    }
+
+    for i, path := range expected {
+        if paths[i] != path {
+            t.Errorf("Expected rows %+v, got %+v", expected, paths)
+            break
+        }
+    }
+}
+
+func TestSectionTreeWidth(t *testing.T) {
+    tree := NewSectionTree(3)
+    tree.MaxChildren = 2
+
+    for _, path := range []string{ `/a/x`, `/b/x`, `/c/x`, `/d/y/z`, `/a/x` } {
+        tree.Add(&NcsaLog{ Path: path, StatusCode: 200 })
+    }
+
+    if len(tree.Root.Children) != 3 {
+        t.Fatalf("Expected 2 children and an %s node, got %d", TREE_OTHER, len(tree.Root.Children))
+    }
+
+    other := tree.Root.Children[TREE_OTHER]
+
+    if other == nil || other.Path != `/` + TREE_OTHER || other.Stat.Count != 2 || len(other.Children) != 0 {
+        t.Fatalf("Expected 2 requests under /%s (with nothing below it), got %+v", TREE_OTHER, other)
+    }
+
+    if tree.Root.Stat.Sizes != nil || tree.Root.Stat.Hosts != nil {
+        t.Errorf("Expected tree nodes to record only counts and statuses")
+    }
+
+//  merged trees are capped in the same way
+    merged := NewSectionTree(3)
+    merged.MaxChildren = 2
+    merged.Add(&NcsaLog{ Path: `/e/x`, StatusCode: 404 })
+    merged.Merge(tree)
+
+    var total uint64
+
+    for _, child := range merged.Root.Children {
+        total += child.Stat.Count
+    }
+
+    if v := merged.Root.Children[TREE_OTHER]; len(merged.Root.Children) > 3 || v == nil || len(v.Children) != 0 || total != 6 {
+        t.Errorf("Expected 6 requests in at most 2 children and an %s node after merging, got %+v", TREE_OTHER, merged.Root.Children)
+    }
+
+    if v := merged.Root.Stat.Statuses; v[200] != 5 || v[404] != 1 {
+        t.Errorf("Unexpected statuses at the root after merging: %+v", v)
+    }
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+    "time"
+)
+
+const TREND_PREVIOUS = `previous`
+const TREND_DAY      = `day`
+const TREND_WEEK     = `week`
+
+const DEFAULT_TREND_THRESHOLD       = 0.5
+const DEFAULT_TREND_ERROR_THRESHOLD = 0.01
+
+// The earlier intervals each interval is compared against: the one immediately before it, and
+// the same interval a day and a week before (an Offset of zero meaning the previous interval).
+//
+var TREND_COMPARISONS = []struct{
+    Name   string
+    Label  string
+    Offset time.Duration
+}{
+    { TREND_PREVIOUS, `prev`, 0                  },
+    { TREND_DAY,      `1d`,   24 * time.Hour     },
+    { TREND_WEEK,     `7d`,   7 * 24 * time.Hour },
+}
+
+// The change in a section's traffic relative to an earlier interval.  Change is the fractional
+// change in the request count (e.g.: 0.25 for 25% more requests), and it and ErrorRatioChange
+// are absent when the section had no requests in the earlier interval.
+//
+type TrendRecord struct {
+    Baseline         uint64   `json:"baseline"`
+    Change           *float64 `json:"change,omitempty"`
+    ErrorRatioChange *float64 `json:"error_ratio_change,omitempty"`
+}
+
+type trendPoint struct {
+    Count      uint64
+    ErrorRatio float64
+}
+
+// Remembers the request count and error ratio of every section seen in recent intervals (as far
+// back as the longest of the TREND_COMPARISONS), so that each interval can be compared to those
+// before it.  Each summary is placed at the timestamp of the latest request in it.  Since a
+// summary covers whatever was read during one (wall-clock) interval, the comparisons against
+// yesterday and last week are only meaningful when following live logs: a replayed file is
+// usually read in just a few intervals, each spanning much more than one interval of log time.
+//
+type TrendHistory struct {
+    Interval  time.Duration
+    snapshots map[int64]map[string]trendPoint
+    latest    time.Time
+}
+
+func NewTrendHistory(interval time.Duration) *TrendHistory {
+    return &TrendHistory{
+        Interval:  interval,
+        snapshots: make(map[int64]map[string]trendPoint),
+    }
+}
+
+// Compare the totals and (displayed) sections of a summary to those of earlier intervals (where
+// they were seen), then remember the totals and all of the given sections (including those not
+// displayed) for comparison with later intervals.  The summary is placed at the given (log)
+// time; an interval with no requests, and so no time, is placed just after the latest interval
+// seen.
+//
+func (self *TrendHistory) Apply(at time.Time, record *SummaryRecord, sections StatisticSet) {
+    if at.IsZero() {
+        if self.latest.IsZero() {
+            at = record.Time
+        }else{
+            at = self.latest.Add(self.Interval)
+        }
+    }
+
+    current := at.Truncate(self.Interval)
+    snapshot := make(map[string]trendPoint)
+
+    record.Totals.Trends = self.compare(current, `*`, record.Totals)
+    snapshot[`*`] = trendPoint{ record.Totals.Count, record.Totals.ErrorRatio }
+
+    for i, section := range record.Sections {
+        record.Sections[i].Trends = self.compare(current, section.Key, section)
+    }
+
+    for key, stat := range sections {
+        snapshot[key] = trendPoint{ stat.Count, stat.ErrorRatio() }
+    }
+
+//  an interval may be summarized in several parts (e.g.: when logs are read faster than the
+//  interval), in which case the parts are combined
+    if existing, ok := self.snapshots[current.UnixNano()]; ok {
+        for key, point := range existing {
+            if combined, ok := snapshot[key]; ok && combined.Count + point.Count > 0 {
+                errors := combined.ErrorRatio * float64(combined.Count) + point.ErrorRatio * float64(point.Count)
+                combined.Count += point.Count
+                combined.ErrorRatio = errors / float64(combined.Count)
+                snapshot[key] = combined
+            }else if !ok {
+                snapshot[key] = point
+            }
+        }
+    }
+
+    self.snapshots[current.UnixNano()] = snapshot
+
+    if current.After(self.latest) {
+        self.latest = current
+    }
+
+//  forget intervals too old to be compared against
+    oldest := self.latest.Add(-TREND_COMPARISONS[len(TREND_COMPARISONS) - 1].Offset).UnixNano()
+
+    for start, _ := range self.snapshots {
+        if start < oldest {
+            delete(self.snapshots, start)
+        }
+    }
+}
+
+func (self *TrendHistory) compare(current time.Time, key string, section SectionRecord) map[string]TrendRecord {
+    trends := make(map[string]TrendRecord)
+
+    for _, comparison := range TREND_COMPARISONS {
+        offset := comparison.Offset
+
+        if offset == 0 {
+            offset = self.Interval
+        }
+
+        snapshot, ok := self.snapshots[current.Add(-offset).Truncate(self.Interval).UnixNano()]
+
+        if !ok {
+            continue
+        }
+
+        baseline := snapshot[key]
+        trend := TrendRecord{
+            Baseline: baseline.Count,
+        }
+
+        if baseline.Count > 0 {
+            change := (float64(section.Count) - float64(baseline.Count)) / float64(baseline.Count)
+            errorRatioChange := section.ErrorRatio - baseline.ErrorRatio
+
+            trend.Change = &change
+            trend.ErrorRatioChange = &errorRatioChange
+        }
+
+        trends[comparison.Name] = trend
+    }
+
+    if len(trends) == 0 {
+        return nil
+    }
+
+    return trends
+}
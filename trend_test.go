@@ -0,0 +1,200 @@
+package main
+
+import (
+    "testing"
+    "time"
+
+    "github.com/fatih/color"
+)
+
+func trendTestRecord(at time.Time, counts map[string]uint64, errorRatio float64) (*SummaryRecord, StatisticSet) {
+    record := &SummaryRecord{
+        Time:   at,
+        Totals: SectionRecord{ Key: `*` },
+    }
+
+    sections := make(StatisticSet)
+
+    for key, count := range counts {
+        errors := uint64(errorRatio * float64(count) + 0.5)
+        stat := &LogStatistic{
+            Key:      key,
+            Count:    count,
+            Statuses: map[uint]uint64{ 200: count - errors, 500: errors },
+        }
+
+        sections[key] = stat
+        record.Totals.Count += count
+        record.Sections = append(record.Sections, SectionRecord{
+            Key:        key,
+            Count:      count,
+            ErrorRatio: stat.ErrorRatio(),
+        })
+    }
+
+    return record, sections
+}
+
+func TestTrendHistory(t *testing.T) {
+    history := NewTrendHistory(time.Minute)
+    start := time.Date(2024, time.March, 18, 10, 0, 0, 0, time.UTC)
+
+    first, sections := trendTestRecord(start, map[string]uint64{ `/api`: 100 }, 0.01)
+    history.Apply(first.Time, first, sections)
+
+    if first.Sections[0].Trends != nil || first.Totals.Trends != nil {
+        t.Errorf("Expected no trends without any history, got %v", first.Sections[0].Trends)
+    }
+
+    second, sections := trendTestRecord(start.Add(time.Minute + 5 * time.Second), map[string]uint64{ `/api`: 200, `/new`: 5 }, 0.05)
+    history.Apply(second.Time, second, sections)
+
+    for _, section := range second.Sections {
+        trend, ok := section.Trends[TREND_PREVIOUS]
+
+        if !ok {
+            t.Fatalf("Expected a trend against the previous interval for %s", section.Key)
+        }
+
+        switch section.Key {
+        case `/api`:
+            if trend.Baseline != 100 || trend.Change == nil || *trend.Change != 1 {
+                t.Errorf("Expected /api to have doubled from 100, got %+v", trend)
+            }
+
+            if trend.ErrorRatioChange == nil || *trend.ErrorRatioChange < 0.0399 || *trend.ErrorRatioChange > 0.0401 {
+                t.Errorf("Expected the error ratio to have risen by 0.04, got %v", trend.ErrorRatioChange)
+            }
+        case `/new`:
+            if trend.Baseline != 0 || trend.Change != nil || trend.ErrorRatioChange != nil {
+                t.Errorf("Expected /new to have no baseline, got %+v", trend)
+            }
+        }
+
+        if _, ok := section.Trends[TREND_DAY]; ok {
+            t.Errorf("Expected no trend against yesterday without a day of history")
+        }
+    }
+
+    if trend := second.Totals.Trends[TREND_PREVIOUS]; trend.Baseline != 100 || *trend.Change != 1.05 {
+        t.Errorf("Expected totals to have grown 105%% from 100, got %+v", trend)
+    }
+
+//  the same interval a day later is compared against the day before, but not against the
+//  previous interval, which was never seen
+    later, sections := trendTestRecord(start.Add(24 * time.Hour + 30 * time.Second), map[string]uint64{ `/api`: 50 }, 0.01)
+    history.Apply(later.Time, later, sections)
+
+    if trend, ok := later.Sections[0].Trends[TREND_DAY]; !ok || trend.Baseline != 100 || *trend.Change != -0.5 {
+        t.Errorf("Expected /api to have halved since yesterday, got %+v", trend)
+    }
+
+    if _, ok := later.Sections[0].Trends[TREND_PREVIOUS]; ok {
+        t.Errorf("Expected no trend against an interval that was never seen")
+    }
+
+//  history older than the longest comparison is forgotten
+    oldest, sections := trendTestRecord(time.Now(), map[string]uint64{ `/api`: 1 }, 0)
+    history.Apply(start.Add(8 * 24 * time.Hour), oldest, sections)
+
+    if _, ok := history.snapshots[start.UnixNano()]; ok {
+        t.Errorf("Expected intervals older than a week to be forgotten")
+    }
+}
+
+func TestFormatTrend(t *testing.T) {
+    noColor := color.NoColor
+    color.NoColor = true
+    defer func(){ color.NoColor = noColor }()
+
+    for change, expected := range map[float64]string{
+        0.25:  `↑25%`,
+        -0.1:  `↓10%`,
+        0.001: `→0%`,
+    }{
+        if v := formatTrend(TrendRecord{ Change: &change }); v != expected {
+            t.Errorf("Expected '%s', got '%s'", expected, v)
+        }
+    }
+
+    for errorRatioChange, expected := range map[float64]string{
+        0.021: `→0% err +2.1pp`,
+        -0.05: `→0% err -5.0pp`,
+        0.001: `→0%`,
+    }{
+        unchanged := 0.0
+
+        if v := formatTrend(TrendRecord{ Change: &unchanged, ErrorRatioChange: &errorRatioChange }); v != expected {
+            t.Errorf("Expected '%s', got '%s'", expected, v)
+        }
+    }
+
+    if v := formatTrend(TrendRecord{}); v != `new` {
+        t.Errorf("Expected 'new', got '%s'", v)
+    }
+}
+
+func TestTrendHistoryLogTime(t *testing.T) {
+    history := NewTrendHistory(time.Hour)
+    start := time.Date(2016, time.March, 15, 22, 0, 0, 0, time.UTC)
+    now := time.Now()
+
+//  replayed logs are summarized moments apart, but placed by their own timestamps; the first
+//  interval is summarized in two parts
+    for _, part := range []struct{
+        Offset     time.Duration
+        Count      uint64
+        ErrorRatio float64
+    }{
+        { 10 * time.Minute, 30, 0   },
+        { 50 * time.Minute, 10, 0.4 },
+    }{
+        record, sections := trendTestRecord(now, map[string]uint64{ `/api`: part.Count }, part.ErrorRatio)
+        history.Apply(start.Add(part.Offset), record, sections)
+    }
+
+    nextDay, sections := trendTestRecord(now, map[string]uint64{ `/api`: 60 }, 0.1)
+    history.Apply(start.Add(24 * time.Hour + 5 * time.Minute), nextDay, sections)
+
+    trend, ok := nextDay.Sections[0].Trends[TREND_DAY]
+
+    if !ok || trend.Baseline != 40 || *trend.Change != 0.5 {
+        t.Fatalf("Expected /api to have grown 50%% from 40 since the day before, got %+v", trend)
+    }
+
+    if *trend.ErrorRatioChange < -0.0001 || *trend.ErrorRatioChange > 0.0001 {
+        t.Errorf("Expected the error ratio to be unchanged from the combined 0.1, got %v", *trend.ErrorRatioChange)
+    }
+
+//  an empty interval follows the latest one seen
+    empty, sections := trendTestRecord(now, map[string]uint64{}, 0)
+    history.Apply(time.Time{}, empty, sections)
+
+    if trend, ok := empty.Totals.Trends[TREND_PREVIOUS]; !ok || trend.Baseline != 60 || *trend.Change != -1 {
+        t.Errorf("Expected an empty interval to show a drop from the interval before, got %+v", trend)
+    }
+}
+
+func TestTrendHistoryHiddenSections(t *testing.T) {
+    history := NewTrendHistory(time.Minute)
+    start := time.Date(2024, time.March, 18, 10, 0, 0, 0, time.UTC)
+
+//  only the busiest section is displayed at first, but the other is still remembered
+    first, sections := trendTestRecord(start, map[string]uint64{ `/api`: 100, `/img`: 40 }, 0)
+
+    for i, section := range first.Sections {
+        if section.Key == `/img` {
+            first.Sections = append(first.Sections[:i], first.Sections[i + 1:]...)
+            break
+        }
+    }
+
+    history.Apply(first.Time, first, sections)
+
+    second, sections := trendTestRecord(start.Add(time.Minute), map[string]uint64{ `/img`: 80 }, 0)
+    history.Apply(second.Time, second, sections)
+
+    if trend, ok := second.Sections[0].Trends[TREND_PREVIOUS]; !ok || trend.Baseline != 40 || trend.Change == nil || *trend.Change != 1 {
+        t.Errorf("Expected /img to have doubled from 40 although it was not displayed before, got %+v", trend)
+    }
+}
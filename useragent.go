@@ -0,0 +1,249 @@
+package main
+
+import (
+    _ "embed"
+    "bufio"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "strings"
+)
+
+const UA_KIND_BOT     = `bot`
+const UA_KIND_BROWSER = `browser`
+const UA_KIND_OS      = `os`
+const UA_KIND_DEVICE  = `device`
+
+const UA_DEVICE_BOT   = `bot`
+const UA_DEVICE_OTHER = `other`
+
+const DEFAULT_UA_CACHE_SIZE = 10000
+
+// The rules used to classify user agents, embedded from useragents.rules.
+//
+//go:embed useragents.rules
+var DEFAULT_UA_RULES string
+
+var uaRuleSeparatorRx = regexp.MustCompile(`\t+|\s{2,}`)
+
+// A single user agent classification rule (see useragents.rules for the file format).
+//
+type UserAgentRule struct {
+    Kind    string
+    Name    string
+    Pattern *regexp.Regexp
+}
+
+// What is known about the software that made a request.  Agents that don't match any browser
+// rule take the name of their first product token (e.g.: "MyApp/1.0" is named "MyApp").
+//
+type UserAgentInfo struct {
+    Browser        string
+    BrowserVersion string
+    OS             string
+    OSVersion      string
+    Device         string
+    Bot            bool
+}
+
+// Return the name of the browser (or bot), optionally followed by its major version.
+//
+func (self *UserAgentInfo) Family(withVersion bool) string {
+    if withVersion && self.BrowserVersion != `` {
+        return self.Browser + ` ` + majorVersion(self.BrowserVersion)
+    }
+
+    return self.Browser
+}
+
+// Return the name of the operating system, optionally followed by its major version.
+//
+func (self *UserAgentInfo) Platform(withVersion bool) string {
+    if withVersion && self.OSVersion != `` {
+        return self.OS + ` ` + majorVersion(self.OSVersion)
+    }
+
+    return self.OS
+}
+
+// Classifies user agent strings using an ordered list of rules, caching the results by
+// user agent string (since most logs contain relatively few distinct agents).
+//
+type UserAgentClassifier struct {
+    Rules []*UserAgentRule
+
+    cache *LRUCache[string, *UserAgentInfo]
+}
+
+// The classifier used for the user agent grouping keys.
+//
+var UserAgents = mustLoadDefaultUserAgentRules()
+
+func NewUserAgentClassifier(cacheSize int) *UserAgentClassifier {
+    return &UserAgentClassifier{
+        Rules: make([]*UserAgentRule, 0),
+        cache: NewLRUCache[string, *UserAgentInfo](cacheSize),
+    }
+}
+
+func mustLoadDefaultUserAgentRules() *UserAgentClassifier {
+    classifier := NewUserAgentClassifier(DEFAULT_UA_CACHE_SIZE)
+
+    if err := classifier.LoadRules(strings.NewReader(DEFAULT_UA_RULES), false); err != nil {
+        panic(err.Error())
+    }
+
+    return classifier
+}
+
+// Load the rules from the given file, checking them before any already loaded.
+//
+func (self *UserAgentClassifier) LoadRulesFile(path string) error {
+    if file, err := os.Open(path); err == nil {
+        defer file.Close()
+
+        if err := self.LoadRules(file, true); err != nil {
+            return fmt.Errorf("%s: %v", path, err)
+        }
+
+        return nil
+    }else{
+        return err
+    }
+}
+
+// Parse rules from the given reader, adding them before (if precede is true) or after the
+// rules already loaded.  Any previously cached classifications are discarded.
+//
+func (self *UserAgentClassifier) LoadRules(reader io.Reader, precede bool) error {
+    rules := make([]*UserAgentRule, 0)
+    scanner := bufio.NewScanner(reader)
+    lineNumber := 0
+
+    for scanner.Scan() {
+        lineNumber += 1
+        line := strings.TrimSpace(scanner.Text())
+
+        if line == `` || strings.HasPrefix(line, `#`) {
+            continue
+        }
+
+        columns := uaRuleSeparatorRx.Split(line, 3)
+
+        if len(columns) != 3 {
+            return fmt.Errorf("line %d: expected a kind, name and pattern", lineNumber)
+        }
+
+        switch columns[0] {
+        case UA_KIND_BOT, UA_KIND_BROWSER, UA_KIND_OS, UA_KIND_DEVICE:
+        default:
+            return fmt.Errorf("line %d: unknown rule kind '%s'", lineNumber, columns[0])
+        }
+
+        if rx, err := regexp.Compile(`(?i)` + columns[2]); err == nil {
+            if columns[1] == `$1` && rx.NumSubexp() < 1 {
+                return fmt.Errorf("line %d: pattern must contain a capture group to be used as the name", lineNumber)
+            }
+
+            rules = append(rules, &UserAgentRule{
+                Kind:    columns[0],
+                Name:    columns[1],
+                Pattern: rx,
+            })
+        }else{
+            return fmt.Errorf("line %d: invalid pattern: %v", lineNumber, err)
+        }
+    }
+
+    if err := scanner.Err(); err != nil {
+        return err
+    }
+
+    if precede {
+        self.Rules = append(rules, self.Rules...)
+    }else{
+        self.Rules = append(self.Rules, rules...)
+    }
+
+    self.cache = NewLRUCache[string, *UserAgentInfo](self.cache.Capacity)
+
+    return nil
+}
+
+// Classify the given user agent string.  An empty user agent is classified as "-" throughout.
+//
+func (self *UserAgentClassifier) Classify(ua string) *UserAgentInfo {
+    if info, ok := self.cache.Get(ua); ok {
+        return info
+    }
+
+    info := &UserAgentInfo{
+        Browser: `-`,
+        OS:      `-`,
+        Device:  `-`,
+    }
+
+    if fields := strings.Fields(ua); len(fields) > 0 {
+        info.Browser = strings.SplitN(fields[0], `/`, 2)[0]
+        info.OS = UA_DEVICE_OTHER
+        info.Device = UA_DEVICE_OTHER
+
+        matched := make(map[string]bool)
+
+        for _, rule := range self.Rules {
+            if matched[rule.Kind] || (info.Bot && rule.Kind == UA_KIND_BROWSER) {
+                continue
+            }
+
+            if name, version, ok := rule.match(ua); ok {
+                matched[rule.Kind] = true
+
+                switch rule.Kind {
+                case UA_KIND_BOT:
+                    info.Bot = true
+                    info.Browser = name
+                    info.BrowserVersion = version
+                case UA_KIND_BROWSER:
+                    info.Browser = name
+                    info.BrowserVersion = version
+                case UA_KIND_OS:
+                    info.OS = name
+                    info.OSVersion = version
+                case UA_KIND_DEVICE:
+                    info.Device = name
+                }
+            }
+        }
+
+        if info.Bot {
+            info.Device = UA_DEVICE_BOT
+        }
+    }
+
+    self.cache.Put(ua, info)
+
+    return info
+}
+
+func (self *UserAgentRule) match(ua string) (string, string, bool) {
+    match := self.Pattern.FindStringSubmatch(ua)
+
+    if match == nil {
+        return ``, ``, false
+    }
+
+    if self.Name == `$1` {
+        return match[1], ``, true
+    }
+
+    if len(match) > 1 {
+        return self.Name, strings.Replace(match[1], `_`, `.`, -1), true
+    }
+
+    return self.Name, ``, true
+}
+
+func majorVersion(version string) string {
+    return strings.SplitN(version, `.`, 2)[0]
+}
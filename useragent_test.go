@@ -0,0 +1,76 @@
+package main
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestUserAgentClassify(t *testing.T) {
+    for ua, expected := range map[string]UserAgentInfo{
+        `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.109 Safari/537.36`: {
+            Browser: `Chrome`, BrowserVersion: `120.0.6099.109`, OS: `Windows`, OSVersion: `10.0`, Device: `desktop`,
+        },
+        `Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.91`: {
+            Browser: `Edge`, BrowserVersion: `120.0.2210.91`, OS: `Windows`, OSVersion: `10.0`, Device: `desktop`,
+        },
+        `Mozilla/5.0 (iPhone; CPU iPhone OS 17_1_2 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.1.2 Mobile/15E148 Safari/604.1`: {
+            Browser: `Safari`, BrowserVersion: `17.1.2`, OS: `iOS`, OSVersion: `17.1.2`, Device: `mobile`,
+        },
+        `Mozilla/5.0 (Linux; Android 13; SM-X200) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/119.0.0.0 Safari/537.36`: {
+            Browser: `Chrome`, BrowserVersion: `119.0.0.0`, OS: `Android`, OSVersion: `13`, Device: `tablet`,
+        },
+        `Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0`: {
+            Browser: `Firefox`, BrowserVersion: `121.0`, OS: `Linux`, Device: `desktop`,
+        },
+        `Mozilla/5.0 (Linux; Android 6.0.1; Nexus 5X Build/MMB29P) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.6099.129 Mobile Safari/537.36 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)`: {
+            Browser: `Googlebot`, BrowserVersion: `2.1`, OS: `Android`, OSVersion: `6.0.1`, Device: `bot`, Bot: true,
+        },
+        `Mozilla/5.0 (compatible; MJ12bot/v1.4.8; http://mj12bot.com/)`: {
+            Browser: `MJ12bot`, OS: `other`, Device: `bot`, Bot: true,
+        },
+        `curl/8.4.0`: {
+            Browser: `curl`, BrowserVersion: `8.4.0`, OS: `other`, Device: `bot`, Bot: true,
+        },
+        `MyApp/1.0 (build 42)`: {
+            Browser: `MyApp`, OS: `other`, Device: `other`,
+        },
+        ``: {
+            Browser: `-`, OS: `-`, Device: `-`,
+        },
+    }{
+        if info := UserAgents.Classify(ua); *info != expected {
+            t.Errorf("Expected '%s' to be classified as %+v, got %+v", ua, expected, *info)
+        }
+    }
+}
+
+func TestUserAgentCustomRules(t *testing.T) {
+    classifier := NewUserAgentClassifier(DEFAULT_UA_CACHE_SIZE)
+
+    if err := classifier.LoadRules(strings.NewReader(DEFAULT_UA_RULES), false); err != nil {
+        t.Fatalf("Failed to load default rules: %v", err)
+    }
+
+    if v := classifier.Classify(`InternalMonitor/2.0`); v.Bot {
+        t.Errorf("Expected an unknown agent not to be a bot")
+    }
+
+    if err := classifier.LoadRules(strings.NewReader("bot  Monitor  ^internalmonitor/([\\d.]+)\n"), true); err != nil {
+        t.Fatalf("Failed to load custom rules: %v", err)
+    }
+
+    if v := classifier.Classify(`InternalMonitor/2.0`); !v.Bot || v.Browser != `Monitor` || v.BrowserVersion != `2.0` {
+        t.Errorf("Expected the custom rule to classify the agent as a bot, got %+v", v)
+    }
+
+    for _, rules := range []string{
+        `bot Monitor`,
+        `robot  Monitor  monitor`,
+        `bot  $1  monitor`,
+        `bot  Monitor  (unclosed`,
+    }{
+        if err := classifier.LoadRules(strings.NewReader(rules), true); err == nil {
+            t.Errorf("Expected an error loading rules '%s'", rules)
+        }
+    }
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+    "time"
+)
+
+const MIN_TRACKED_RATE = 0.001
+
+// A Window accumulates statistics into buckets (one per resolution tick), and keeps
+// enough completed buckets in a ring to summarize any span up to the size of the window.
+// Tumbling intervals and sliding windows are both produced by merging the most recent
+// buckets; they differ only in how many buckets are merged.
+//
+type Window struct {
+    Resolution time.Duration
+    Size       time.Duration
+
+    Options      BucketOptions
+
+    buckets      *Ring[*Bucket]
+    current      *Bucket
+    sinceSummary int
+    rateWindows  []time.Duration
+    rates        map[string][]*EWMA
+}
+
+func NewWindow(resolution time.Duration, size time.Duration) *Window {
+    return NewWindowWithOptions(resolution, size, BucketOptions{})
+}
+
+func NewWindowWithOptions(resolution time.Duration, size time.Duration, options BucketOptions) *Window {
+    return &Window{
+        Resolution: resolution,
+        Size:       size,
+        Options:    options,
+        buckets:    NewRing[*Bucket](int(size / resolution)),
+        current:    NewBucket(options),
+        rates:      make(map[string][]*EWMA),
+    }
+}
+
+// Maintain exponentially-weighted hit rates for every section, decaying over each of the
+// given windows.
+//
+func (self *Window) TrackRates(windows []time.Duration) {
+    self.rateWindows = windows
+}
+
+// Record a log line under the given section in the current bucket.
+//
+func (self *Window) Add(section string, logLine *NcsaLog) {
+    self.current.Sections.Add(section, logLine)
+}
+
+// Record a log line in all of the current bucket's section-independent aggregates.
+//
+func (self *Window) Observe(logLine *NcsaLog) {
+    self.current.Observe(logLine)
+}
+
+// Record a session that has ended in the current bucket (if sessions are being tracked).
+//
+func (self *Window) ObserveSession(session *Session) {
+    if self.current.Sessions != nil {
+        self.current.Sessions.Add(session)
+    }
+}
+
+// Record a move from one page to another within a session in the current bucket (if page
+// transitions are being tracked).
+//
+func (self *Window) ObserveTransition(from string, to string) {
+    if self.current.Transitions != nil {
+        self.current.Transitions.Add(from, to)
+    }
+}
+
+// Close out the current bucket and start a new one.  This should be called once per
+// resolution tick.
+//
+func (self *Window) Advance() {
+    if len(self.rateWindows) > 0 {
+        self.updateRates()
+    }
+
+    self.current.Duration = self.Resolution
+    self.buckets.Push(self.current)
+    self.current = NewBucket(self.Options)
+    self.sinceSummary += 1
+}
+
+// Merge the completed buckets covering the given span of time (up to the window size).
+//
+func (self *Window) Span(span time.Duration) *Bucket {
+    return self.merge(int(span / self.Resolution))
+}
+
+// Return a copy of the current hit rates (per second) for each section, in the same order
+// as the windows given to TrackRates.
+//
+func (self *Window) Rates() map[string][]float64 {
+    rates := make(map[string][]float64)
+
+    for section, averages := range self.rates {
+        values := make([]float64, len(averages))
+
+        for i, average := range averages {
+            values[i] = average.Rate
+        }
+
+        rates[section] = values
+    }
+
+    return rates
+}
+
+// Merge all completed buckets covering the window.
+//
+func (self *Window) Sliding() *Bucket {
+    self.sinceSummary = 0
+    return self.merge(self.buckets.Length())
+}
+
+// Merge only those buckets that have been completed since the last summary was taken.
+//
+func (self *Window) Tumbling() *Bucket {
+    merged := self.merge(self.sinceSummary)
+    self.sinceSummary = 0
+    return merged
+}
+
+func (self *Window) merge(n int) *Bucket {
+    merged := NewBucket(self.Options)
+
+    for _, bucket := range self.buckets.Last(n) {
+        merged.Merge(bucket)
+    }
+
+    return merged
+}
+
+func (self *Window) updateRates() {
+    for section := range self.current.Sections {
+        if _, ok := self.rates[section]; !ok {
+            averages := make([]*EWMA, len(self.rateWindows))
+
+            for i, w := range self.rateWindows {
+                averages[i] = NewEWMA(w)
+            }
+
+            self.rates[section] = averages
+        }
+    }
+
+    for section, averages := range self.rates {
+        var count uint64
+        var active bool
+
+        if stat, ok := self.current.Sections[section]; ok {
+            count = stat.Count
+        }
+
+        for _, average := range averages {
+            average.Update(count, self.Resolution)
+
+            if average.Rate >= MIN_TRACKED_RATE {
+                active = true
+            }
+        }
+
+    //  stop tracking sections whose rates have all decayed to (effectively) zero
+        if !active {
+            delete(self.rates, section)
+        }
+    }
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+    "testing"
+    "time"
+)
+
+func TestWindowTumbling(t *testing.T) {
+    window := NewWindow(time.Second, 2 * time.Second)
+
+    window.Add(`api`, &NcsaLog{ StatusCode: 200 })
+    window.Advance()
+    window.Add(`api`, &NcsaLog{ StatusCode: 404 })
+    window.Advance()
+
+    if stats := window.Tumbling().Sections; stats[`api`].Count != 2 {
+        t.Errorf("Expected 2 hits in the first interval, got %d", stats[`api`].Count)
+    }
+
+    window.Add(`api`, &NcsaLog{ StatusCode: 500 })
+    window.Advance()
+
+    if stats := window.Tumbling().Sections; stats[`api`].Count != 1 {
+        t.Errorf("Expected 1 hit in the second interval, got %d", stats[`api`].Count)
+    }else if stats[`api`].GroupByStatusFamily()[`5xx`] != 1 {
+        t.Errorf("Expected a single 5xx in the second interval, got %+v", stats[`api`].Statuses)
+    }
+}
+
+func TestWindowSliding(t *testing.T) {
+    window := NewWindow(time.Second, 3 * time.Second)
+
+    for i := 1; i <= 5; i++ {
+        for j := 0; j < i; j++ {
+            window.Add(`api`, &NcsaLog{ StatusCode: 200 })
+        }
+
+        window.Advance()
+    }
+
+//  only the last 3 buckets (3 + 4 + 5 hits) should be in the window
+    if stats := window.Sliding().Sections; stats[`api`].Count != 12 {
+        t.Errorf("Expected 12 hits in the sliding window, got %d", stats[`api`].Count)
+    }
+}
+
+func TestWindowSpansAndRates(t *testing.T) {
+    window := NewWindow(time.Second, 4 * time.Second)
+    window.TrackRates([]time.Duration{ time.Second, time.Minute })
+
+    for i := 0; i < 4; i++ {
+        window.Add(`api`, &NcsaLog{ StatusCode: 200 })
+        window.Add(`api`, &NcsaLog{ StatusCode: 503 })
+        window.Advance()
+    }
+
+    if stats := window.Span(2 * time.Second).Sections; stats[`api`].Count != 4 {
+        t.Errorf("Expected 4 hits in a 2s span, got %d", stats[`api`].Count)
+    }else if ratio := stats[`api`].ErrorRatio(); ratio != 0.5 {
+        t.Errorf("Expected an error ratio of 0.5, got %f", ratio)
+    }
+
+    if stats := window.Span(4 * time.Second).Sections; stats[`api`].Count != 8 {
+        t.Errorf("Expected 8 hits in a 4s span, got %d", stats[`api`].Count)
+    }
+
+//  the averages start at zero, so the shorter window approaches the true rate sooner
+    if rates := window.Rates()[`api`]; len(rates) != 2 || rates[0] < 1.9 || rates[0] > 2 || rates[1] <= 0 || rates[1] >= rates[0] {
+        t.Errorf("Expected the rates to be rising toward 2/sec, got %+v", rates)
+    }
+
+    for i := 0; i < 300; i++ {
+        window.Add(`api`, &NcsaLog{ StatusCode: 200 })
+        window.Add(`api`, &NcsaLog{ StatusCode: 200 })
+        window.Advance()
+    }
+
+    steady := window.Rates()[`api`]
+
+    if steady[0] < 1.99 || steady[0] > 2 || steady[1] < 1.9 || steady[1] > 2 {
+        t.Errorf("Expected the rates to converge on 2/sec, got %+v", steady)
+    }
+
+    for i := 0; i < 10; i++ {
+        window.Advance()
+    }
+
+    if rates := window.Rates()[`api`]; rates[0] > 0.01 || rates[1] >= steady[1] || rates[1] < 1 {
+        t.Errorf("Expected the rates to decay once traffic stopped, got %+v", rates)
+    }
+}
+
+func TestWindowBandwidth(t *testing.T) {
+    window := NewWindowWithOptions(time.Second, 2 * time.Second, BucketOptions{ TopBandwidth: 1 })
+    start := time.Date(2016, time.March, 15, 22, 58, 38, 0, time.UTC)
+
+    for i, client := range []string{ `10.0.0.1`, `10.0.0.2`, `10.0.0.1` } {
+        logLine := &NcsaLog{ Host: client, Size: 1000, Timestamp: start.Add(time.Duration(i) * 5 * time.Second) }
+
+        window.Observe(logLine)
+        window.Add(`api`, logLine)
+    }
+
+    window.Advance()
+    summary := window.Tumbling()
+
+    if summary.Totals.Bytes != 3000 || summary.Sections[`api`].Bytes != 3000 {
+        t.Errorf("Expected 3000 bytes served, got %d", summary.Totals.Bytes)
+    }
+
+//  the log timestamps span 10 seconds, which is longer than the single bucket summarized
+    if elapsed := summary.Elapsed(); elapsed != 10 * time.Second {
+        t.Errorf("Expected an elapsed time of 10s, got %v", elapsed)
+    }
+
+    if top := NewBandwidthRecords(summary.ClientBytes, 1, summary.Elapsed()); len(top) != 1 || top[0].Client != `10.0.0.1` || top[0].Bytes != 2000 || top[0].BytesPerSecond != 200 {
+        t.Errorf("Unexpected top bandwidth consumers: %+v", top)
+    }
+}